@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"trex_utils"
+
+	"github.com/charmbracelet/huh"
+)
+
+// nonInteractiveConfig is set from --defaults in main(); when true (or
+// when every prompted key is already overridden by a TREX_* env var, see
+// shouldPromptConfig) createDefaultConfig skips the wizard entirely, so
+// CI and scripted installs never block on a prompt.
+var nonInteractiveConfig bool
+
+// configValues holds the answers the wizard collects (or the hardcoded
+// defaults, for --defaults runs), one field per prompted .trexrc key.
+type configValues struct {
+	promptSymbol     string
+	promptColor      string
+	theme            string
+	pythonExecutable string
+	historySize      string
+	modulePaths      string
+}
+
+func hardcodedConfigDefaults() configValues {
+	return configValues{
+		promptSymbol:     "❯",
+		promptColor:      "cyan",
+		theme:            "default",
+		pythonExecutable: detectPythonExecutable(),
+		historySize:      "1000",
+		modulePaths:      "~/.t-rex/modules",
+	}
+}
+
+// detectPythonExecutable looks for a usable interpreter on PATH, falling
+// back to the "python3" name modules are invoked with today.
+func detectPythonExecutable() string {
+	for _, name := range []string{"python3", "python"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return "python3"
+}
+
+// discoverThemes lists the themes the wizard can offer; "default" is
+// always valid even before a real theme registry exists.
+func discoverThemes() []string {
+	return []string{"default", "dark", "light", "minimal"}
+}
+
+// createDefaultConfig creates a .trexrc for a first run, either by
+// prompting the user with a TUI wizard or, for --defaults / CI, by
+// writing the hardcoded defaults straight away (preserving the previous
+// static-file behavior for scripted installs).
+func createDefaultConfig(configPath string) {
+	defaults := hardcodedConfigDefaults()
+
+	if nonInteractiveConfig || !shouldPromptConfig() {
+		writeConfig(configPath, defaults, defaults)
+		return
+	}
+
+	chosen := defaults
+	if err := runConfigWizard(&chosen); err != nil {
+		trex_utils.PrintError("Config wizard failed, writing defaults: " + err.Error())
+		chosen = defaults
+	}
+
+	writeConfig(configPath, chosen, defaults)
+}
+
+// shouldPromptConfig reports whether the wizard has anything left to ask:
+// if every prompted key already has a TREX_<KEY> override set, there's
+// nothing for the user to choose.
+func shouldPromptConfig() bool {
+	keys := []string{"prompt_symbol", "prompt_color", "theme", "python_executable", "history_size", "module_paths"}
+	for _, k := range keys {
+		if os.Getenv(envKeyFor(k)) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// runConfigWizard prompts for each setting with an input, select, or
+// confirm field as appropriate, filling in values in place.
+func runConfigWizard(values *configValues) error {
+	colorOptions := []string{"cyan", "green", "yellow", "red", "magenta", "blue", "white"}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Prompt symbol").
+				Value(&values.promptSymbol),
+			huh.NewSelect[string]().
+				Title("Prompt color").
+				Options(huh.NewOptions(colorOptions...)...).
+				Value(&values.promptColor),
+			huh.NewSelect[string]().
+				Title("Theme").
+				Options(huh.NewOptions(discoverThemes()...)...).
+				Value(&values.theme),
+			huh.NewInput().
+				Title("Python executable").
+				Value(&values.pythonExecutable),
+			huh.NewInput().
+				Title("History size").
+				Validate(func(s string) error {
+					if _, err := strconv.Atoi(s); err != nil {
+						return fmt.Errorf("must be a number")
+					}
+					return nil
+				}).
+				Value(&values.historySize),
+			huh.NewInput().
+				Title("Module paths").
+				Value(&values.modulePaths),
+		),
+	)
+
+	return form.Run()
+}
+
+// writeConfig renders the .trexrc file. Any field in chosen that still
+// matches defaults came from a default, not a deliberate choice, so it's
+// written out as a commented suggestion rather than a live key — keeping
+// the file self-documenting about what it's actually overriding.
+func writeConfig(configPath string, chosen, defaults configValues) {
+	setting := func(b *strings.Builder, key, chosenVal, defaultVal string) {
+		if chosenVal != defaultVal {
+			fmt.Fprintf(b, "%s=%s\n", key, chosenVal)
+		} else {
+			fmt.Fprintf(b, "# %s=%s\n", key, defaultVal)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# T-Rex Shell Configuration\n")
+	setting(&b, "module_paths", chosen.modulePaths, defaults.modulePaths)
+	b.WriteString("use_colors=true\n")
+	setting(&b, "theme", chosen.theme, defaults.theme)
+	b.WriteString("history_enabled=true\n")
+	setting(&b, "history_size", chosen.historySize, defaults.historySize)
+	b.WriteString("\n")
+	b.WriteString("# Prompt customization - use format: prompt_template=%u@%h:%D❯\n")
+	b.WriteString("# %u = username\n")
+	b.WriteString("# %h = hostname\n")
+	b.WriteString("# %w = full working directory\n")
+	b.WriteString("# %d = full working directory (same as %w)\n")
+	b.WriteString("# %D = working directory basename only\n")
+	b.WriteString("# %~ = home directory relative path\n")
+	b.WriteString("\n")
+	setting(&b, "prompt_symbol", chosen.promptSymbol, defaults.promptSymbol)
+	setting(&b, "prompt_template", chosen.promptSymbol, defaults.promptSymbol)
+	setting(&b, "prompt_color", chosen.promptColor, defaults.promptColor)
+	setting(&b, "python_executable", chosen.pythonExecutable, defaults.pythonExecutable)
+	b.WriteString("\n")
+	b.WriteString("# Alternative default renderer for command/pipeline results, in place of\n")
+	b.WriteString("# pretty-printed JSON. Uses the same {{var}} / {{#each}} / {{#if}} syntax\n")
+	b.WriteString("# as the \"template\" pipeline stage, e.g.:\n")
+	b.WriteString("# output_template={{#if error}}error: {{error}}{{else}}{{output}}{{/if}}\n")
+
+	os.WriteFile(configPath, []byte(b.String()), 0644)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+)
+
+// applyGoTemplateStage renders tmplSrc as a Go text/template against output
+// (result["output"], the same data select/columns/map operate on), kubectl
+// `-o go-template=...` style — e.g. "{{range .items}}{{.name}}\t{{.size}}\n{{end}}".
+// This is distinct from the pre-existing Handlebars-style `template` stage
+// (src/trex_utils/template): that stage already owns the `template` pipeline
+// keyword, so this one is exposed as `gotemplate` to avoid colliding with it
+// while still giving callers the real text/template grammar.
+func applyGoTemplateStage(output interface{}, tmplSrc string) (string, error) {
+	tpl, err := template.New("gotemplate").Parse(tmplSrc)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tpl.Execute(&b, output); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
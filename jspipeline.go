@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// dotRefRe matches a jq-style leading-dot field reference (".size",
+// ".name") that isn't already part of a longer token (a number literal
+// like "1.5", or a property access like "foo.bar"), so map/filter/reduce
+// expressions can write ".field" instead of "$.field".
+var dotRefRe = regexp.MustCompile(`(^|[^\w$)\]])\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// rewriteDotRefs turns jq-style ".field" references into "$.field" so
+// the expression becomes valid JavaScript evaluated against $, the
+// current element.
+func rewriteDotRefs(expr string) string {
+	return dotRefRe.ReplaceAllString(expr, "${1}$$.$2")
+}
+
+// newPipelineVM creates a goja runtime with a small helper API: $ (bound
+// per-call to the current element), _ (an object exposing keys/values/len),
+// and top-level keys()/values()/len() for convenience.
+func newPipelineVM() *goja.Runtime {
+	vm := goja.New()
+
+	keysFn := func(v interface{}) []string {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		out := make([]string, 0, len(m))
+		for k := range m {
+			out = append(out, k)
+		}
+		return out
+	}
+	valuesFn := func(v interface{}) []interface{} {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		out := make([]interface{}, 0, len(m))
+		for _, val := range m {
+			out = append(out, val)
+		}
+		return out
+	}
+	lenFn := func(v interface{}) int {
+		switch t := v.(type) {
+		case []interface{}:
+			return len(t)
+		case map[string]interface{}:
+			return len(t)
+		case string:
+			return len(t)
+		default:
+			return 0
+		}
+	}
+
+	vm.Set("keys", keysFn)
+	vm.Set("values", valuesFn)
+	vm.Set("len", lenFn)
+	vm.Set("_", map[string]interface{}{"keys": keysFn, "values": valuesFn, "len": lenFn})
+
+	return vm
+}
+
+// evalPipelineExpr evaluates expr (after dot-ref rewriting) with $ bound
+// to elem, returning the exported Go value.
+func evalPipelineExpr(vm *goja.Runtime, expr string, elem interface{}) (interface{}, error) {
+	vm.Set("$", elem)
+	v, err := vm.RunString(rewriteDotRefs(expr))
+	if err != nil {
+		return nil, fmt.Errorf("expression error: %w", err)
+	}
+	return v.Export(), nil
+}
+
+// applyMapStage evaluates expr against every element of an array output
+// (or once against a single object/scalar output), replacing each with
+// the expression's result.
+func applyMapStage(output interface{}, expr string) (interface{}, error) {
+	vm := newPipelineVM()
+
+	if arr, ok := output.([]interface{}); ok {
+		mapped := make([]interface{}, len(arr))
+		for i, elem := range arr {
+			v, err := evalPipelineExpr(vm, expr, elem)
+			if err != nil {
+				return nil, err
+			}
+			mapped[i] = v
+		}
+		return mapped, nil
+	}
+
+	return evalPipelineExpr(vm, expr, output)
+}
+
+// applyFilterStage keeps only the array elements for which expr is truthy.
+func applyFilterStage(output interface{}, expr string) (interface{}, error) {
+	arr, ok := output.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("filter: expected an array output, got %T", output)
+	}
+
+	vm := newPipelineVM()
+	var kept []interface{}
+	for _, elem := range arr {
+		v, err := evalPipelineExpr(vm, expr, elem)
+		if err != nil {
+			return nil, err
+		}
+		if vm.ToValue(v).ToBoolean() {
+			kept = append(kept, elem)
+		}
+	}
+	return kept, nil
+}
+
+// applyReduceStage folds an array output down to a single value via expr,
+// which is evaluated once per element with $ bound to the element and
+// acc bound to the running accumulator; expr's result becomes the new
+// accumulator. init, if non-empty, seeds the accumulator (evaluated as a
+// JS expression); otherwise it starts at null.
+func applyReduceStage(output interface{}, expr, init string) (interface{}, error) {
+	arr, ok := output.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("reduce: expected an array output, got %T", output)
+	}
+
+	vm := newPipelineVM()
+
+	var acc interface{}
+	if strings.TrimSpace(init) != "" {
+		v, err := vm.RunString(init)
+		if err != nil {
+			return nil, fmt.Errorf("reduce: invalid initial value: %w", err)
+		}
+		acc = v.Export()
+	}
+
+	for _, elem := range arr {
+		vm.Set("acc", acc)
+		v, err := evalPipelineExpr(vm, expr, elem)
+		if err != nil {
+			return nil, err
+		}
+		acc = v
+	}
+
+	return acc, nil
+}
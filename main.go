@@ -1,22 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	texttemplate "text/template"
+	"time"
 
+	"trex_config"
 	"trex_errors"
 	"trex_modules"
 	"trex_utils"
+	"trex_utils/template"
 )
 
 const Version = "1.0.0"
 
+// globalJobs is the default worker-pool size for a forloop/foreach
+// "parallel" clause that doesn't specify its own N. Set once in main()
+// from -j/--jobs, then TREX_JOBS, then runtime.NumCPU().
+var globalJobs = runtime.NumCPU()
+
 func main() {
 	// Define command-line flags
 	pathFlag := flag.String("path", "", "Path to custom modules directory")
@@ -26,8 +42,30 @@ func main() {
 
 	verbose_flag := flag.Bool("vv", false, "Verbse to show logs and descripeted error messages (default: false)")
 
+	jFlag := flag.Int("j", 0, "Default worker count for forloop/foreach \"parallel\" clauses (default: runtime.NumCPU())")
+	jobsFlag := flag.Int("jobs", 0, "Alias for -j")
+
+	planFlag := flag.Bool("plan", false, "Compile the script into a dependency DAG and run independent commands concurrently")
+	planDumpFlag := flag.Bool("plan-dump", false, "Print the resolved DAG for the script argument and exit, without running it")
+
+	errorFormatFlag := flag.String("error-format", "text", "Diagnostic output format: text or json")
+
+	defaultsFlag := flag.Bool("defaults", false, "Skip the first-run config wizard and write hardcoded defaults (for scripted installs)")
+
 	flag.Parse()
 
+	nonInteractiveConfig = *defaultsFlag
+
+	if *jFlag > 0 {
+		globalJobs = *jFlag
+	} else if *jobsFlag > 0 {
+		globalJobs = *jobsFlag
+	} else if env := os.Getenv("TREX_JOBS"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n > 0 {
+			globalJobs = n
+		}
+	}
+
 	// If a non-flag positional argument is provided and it's a file, execute it as script
 	args := flag.Args()
 
@@ -51,11 +89,17 @@ func main() {
 	}
 
 	shell := NewShell()
+	shell.planMode = *planFlag
+	shell.errorFormat = *errorFormatFlag
 
 	// If a file path was passed as positional arg, execute file and exit
 	if len(args) > 0 {
 		candidate := args[0]
 		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			if *planDumpFlag {
+				shell.DumpPlan(candidate)
+				os.Exit(0)
+			}
 			shell.ExecuteFile(candidate, vv)
 			os.Exit(0)
 		}
@@ -95,6 +139,64 @@ type Shell struct {
 	promptColor    trex_utils.Color
 	promptTemplate string
 	vars           map[string]string
+
+	lastOutput       string // most recent pipeline output, for %{@}
+	currentScriptDir string // directory of the script being run, for %{path:...}
+	outputTemplate   string // output_template=... from .trexrc; "" disables it
+
+	outWriter io.Writer // nil means os.Stdout; set on per-iteration forks, see forkVars
+	planMode  bool      // --plan: always run ExecuteFile's scripts through the DAG scheduler
+
+	errorFormat string // --error-format: "text" (default) or "json", see (*Shell).renderer
+
+	settings map[string]string // every key=value from .trexrc, after TREX_<KEY> env overrides; see loadConfig
+
+	lastExitCode int           // exit code of the last command, for the exit_code prompt helper
+	lastDuration time.Duration // wall time of the last command, for the duration prompt helper
+
+	promptTmpl    *texttemplate.Template // compiled form of promptTemplate, cached until it changes; see renderPrompt
+	promptTmplSrc string                 // the source promptTmpl was compiled from
+
+	configPath string                // path to .trexrc, for "config set" to persist to
+	configDoc  *trex_config.Document // schema-validated, comment/order-preserving .trexrc, see loadConfig
+
+	registry *trex_modules.Registry // sources added with "module add", see handleModuleCommand
+}
+
+// renderer returns the trex_errors.Renderer diagnostics should be printed
+// through, configured from this shell's color and --error-format settings.
+func (s *Shell) renderer() *trex_errors.Renderer {
+	return &trex_errors.Renderer{
+		TabWidth: 4,
+		Color:    s.useColors,
+		JSON:     s.errorFormat == "json",
+	}
+}
+
+// out returns the writer command output should go to: the real stdout,
+// unless this Shell is a per-iteration fork from a parallel
+// forloop/foreach, in which case it's a private buffer the caller
+// flushes once that iteration completes.
+func (s *Shell) out() io.Writer {
+	if s.outWriter != nil {
+		return s.outWriter
+	}
+	return os.Stdout
+}
+
+// forkVars returns a shallow copy of the shell with its own vars map
+// (seeded from the parent's) with name set to value, so concurrent
+// forloop/foreach iterations never race on a shared map entry. Every
+// other field (loader, executor, history, ...) is shared, same as they
+// already are across sequential iterations.
+func (s *Shell) forkVars(name, value string) *Shell {
+	child := *s
+	child.vars = make(map[string]string, len(s.vars)+1)
+	for k, v := range s.vars {
+		child.vars[k] = v
+	}
+	child.vars[name] = value
+	return &child
 }
 
 // NewShell creates a new shell instance
@@ -113,6 +215,11 @@ func NewShell() *Shell {
 		vars:           make(map[string]string),
 	}
 
+	shell.registry = trex_modules.NewRegistry(filepath.Join(homeDir, ".t-rex"))
+	for _, path := range shell.registry.ResolvedPaths() {
+		shell.loader.AddPath(path)
+	}
+
 	loadConfig(shell)
 	return shell
 }
@@ -153,11 +260,13 @@ func (s *Shell) Run(verbose bool) {
 	editor := trex_utils.NewLineEditor(s.history)
 
 	for {
-		prompt := trex_utils.BuildPrompt("❯", s.promptColor, true, true, false)
+		prompt := s.renderPrompt()
 		line, err := editor.ReadLine(prompt)
 		if err != nil {
 			fmt.Println()
 			trex_utils.PrintExit("Goodbye! 👋")
+			s.loader.Shutdown()
+			s.history.Close()
 			os.Exit(0)
 		}
 
@@ -169,17 +278,40 @@ func (s *Shell) Run(verbose bool) {
 		// Check for exit
 		if line == "exit" || line == "quit" {
 			trex_utils.PrintExit("Goodbye! 👋")
+			s.loader.Shutdown()
+			s.history.Close()
 			os.Exit(0)
 		}
 
-		s.history.Add(line)
-		if err := s.executeCommand(line, verbose); err != nil {
-			// error already logged/printed by lower-level handlers
-			trex_utils.PrintError(err.Error())
-		}
+		s.recordAndExecute(line, verbose)
 	}
 }
 
+// recordAndExecute runs line and records it to history with the CWD, exit
+// code, and duration the shell driver observed, so History.Query/Stats
+// have real context to filter and rank on.
+func (s *Shell) recordAndExecute(line string, verbose bool) error {
+	cwd, _ := os.Getwd()
+	start := time.Now()
+
+	err := s.executeCommand(line, verbose)
+	if err != nil {
+		trex_utils.PrintError(err.Error())
+	}
+
+	s.lastExitCode = boolToExitCode(err == nil)
+	s.lastDuration = time.Since(start)
+
+	s.history.AddEntry(trex_utils.HistoryEntry{
+		Cmd:        line,
+		CWD:        cwd,
+		ExitCode:   s.lastExitCode,
+		DurationMs: s.lastDuration.Milliseconds(),
+	})
+
+	return err
+}
+
 // executeCommand processes a command
 func (s *Shell) executeCommand(line string, verbose bool) error {
 	// If the line is a file path, execute file
@@ -234,6 +366,30 @@ func (s *Shell) executeCommand(line string, verbose bool) error {
 		}
 	}
 
+	// Built-in "history" command: plain listing, or an atuin-like
+	// interactive fuzzy picker via "history --search"
+	if len(parts) > 0 && parts[0] == "history" {
+		return s.handleHistory(parts[1:])
+	}
+
+	// Built-in "prompt test <template>": render a prompt_template value
+	// against the current context without starting the interactive shell.
+	if len(parts) > 0 && parts[0] == "prompt" {
+		return s.handlePromptCommand(parts[1:])
+	}
+
+	// Built-in "config get/set/list": read and write .trexrc through the
+	// schema-validated Document loadConfig parsed at startup.
+	if len(parts) > 0 && parts[0] == "config" {
+		return s.handleConfigCommand(parts[1:])
+	}
+
+	// Built-in "module add/list/remove/update": manage module_paths
+	// registry sources (local dirs, git remotes, HTTP archives).
+	if len(parts) > 0 && parts[0] == "module" {
+		return s.handleModuleCommand(parts[1:])
+	}
+
 	// Check for forloop pattern: forloop RANGE as $var do { ... }
 	handled, err := s.handleForLoop(line, verbose)
 	if err != nil {
@@ -262,12 +418,19 @@ func (s *Shell) executeCommand(line string, verbose bool) error {
 	}
 
 	// expand variables in command name as well
-	cmd := s.expandVars(parts[0])
+	cmd, err := s.expandVars(parts[0])
+	if err != nil {
+		return err
+	}
 	args := parts[1:]
 
 	// Expand variables in args
 	for i, a := range args {
-		args[i] = s.expandVars(a)
+		expanded, err := s.expandVars(a)
+		if err != nil {
+			return err
+		}
+		args[i] = expanded
 	}
 
 	// Try to execute as Python module
@@ -286,9 +449,10 @@ func (s *Shell) executeCommand(line string, verbose bool) error {
 // executePipeline executes a command pipeline that may start with a literal value,
 // array literal, or regular command, and supports piping through modules or special operators.
 func (s *Shell) executePipeline(line string) error {
-	// Split into first part and the rest after first |
-	parts := strings.SplitN(line, "|", 2)
-	firstPart := strings.TrimSpace(parts[0])
+	// Split into stages respecting quoting, so a quoted pipe character
+	// (grep "a|b") stays inside its stage instead of splitting the pipeline.
+	stages := trex_utils.NewPipeline(line).Stages()
+	firstPart := stages[0]
 	if firstPart == "" {
 		return nil
 	}
@@ -346,12 +510,20 @@ func (s *Shell) executePipeline(line string) error {
 		args := cmdParts[1:]
 
 		// Variable expansion
+		var err error
 		for i := range args {
-			args[i] = s.expandVars(args[i])
+			if args[i], err = s.expandVars(args[i]); err != nil {
+				return err
+			}
+		}
+		if cmd, err = s.expandVars(cmd); err != nil {
+			return err
+		}
+
+		if streamArgs, ok := stripStreamFlag(args); ok {
+			return s.runStreamingPipeline(cmd, streamArgs, stages[1:])
 		}
-		cmd = s.expandVars(cmd)
 
-		var err error
 		result, err = s.executeModule(strings.Split(cmd, " "), args)
 		if err != nil {
 			return err
@@ -362,7 +534,7 @@ func (s *Shell) executePipeline(line string) error {
 	}
 
 	// No more pipes → just print and return
-	if len(parts) < 2 {
+	if len(stages) < 2 {
 		s.printResult(result)
 		return nil
 	}
@@ -370,11 +542,7 @@ func (s *Shell) executePipeline(line string) error {
 	// ────────────────────────────────────────────────
 	// Process piped stages
 	// ────────────────────────────────────────────────
-	pipeRest := strings.TrimSpace(parts[1])
-	pipeParts := strings.Split(pipeRest, "|")
-
-	for _, pipe := range pipeParts {
-		pipe = strings.TrimSpace(pipe)
+	for _, pipe := range stages[1:] {
 		if pipe == "" {
 			continue
 		}
@@ -398,10 +566,100 @@ func (s *Shell) executePipeline(line string) error {
 
 		case "tt":
 			result["__table_print"] = true
+			for _, a := range args {
+				if a == "--no-color" {
+					result["__table_no_color"] = true
+				}
+			}
+
+		case "template":
+			if len(args) == 0 {
+				return fmt.Errorf("template: expected a template string or @file argument")
+			}
+			src, err := loadTemplateSource(strings.Join(args, " "), s.currentScriptDir)
+			if err != nil {
+				return err
+			}
+			tpl, err := template.Compile(src)
+			if err != nil {
+				return err
+			}
+			rendered, err := tpl.Render(result)
+			if err != nil {
+				return err
+			}
+			result["output"] = rendered
+			result["__raw_print"] = true
+
+		case "gotemplate":
+			if len(args) == 0 {
+				return fmt.Errorf("gotemplate: expected a Go text/template string or @file argument")
+			}
+			src, err := loadTemplateSource(strings.Join(args, " "), s.currentScriptDir)
+			if err != nil {
+				return err
+			}
+			rendered, err := applyGoTemplateStage(result["output"], src)
+			if err != nil {
+				return err
+			}
+			result["output"] = rendered
+			result["__raw_print"] = true
+
+		case "map":
+			if len(args) == 0 {
+				return fmt.Errorf("map: expected a JS expression argument")
+			}
+			out, err := applyMapStage(result["output"], strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+			result["output"] = out
+
+		case "filter":
+			if len(args) == 0 {
+				return fmt.Errorf("filter: expected a JS expression argument")
+			}
+			out, err := applyFilterStage(result["output"], strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+			result["output"] = out
+
+		case "columns":
+			if len(args) == 0 {
+				return fmt.Errorf("columns: expected a NAME:path,NAME:path,... argument")
+			}
+			specs, err := parseColumnSpecs(strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+			rows, err := applyColumnsStage(result["output"], specs)
+			if err != nil {
+				return err
+			}
+			result["output"] = rows
+			result["__table_print"] = true
+			result["__table_columns"] = columnNames(specs)
+
+		case "reduce":
+			if len(args) == 0 {
+				return fmt.Errorf("reduce: expected a JS expression argument, optionally followed by an initial value")
+			}
+			expr := args[0]
+			init := ""
+			if len(args) > 1 {
+				init = strings.Join(args[1:], " ")
+			}
+			out, err := applyReduceStage(result["output"], expr, init)
+			if err != nil {
+				return err
+			}
+			result["output"] = out
 
 		default:
 			// Assume it's a module name
-			modulePath, err := s.loader.FindModule(op)
+			modulePath, _, err := s.loader.FindModule(op)
 			if err != nil || modulePath == "" {
 				return fmt.Errorf("unknown pipeline operator or module: %s", op)
 			}
@@ -456,37 +714,23 @@ func (s *Shell) executePipeline(line string) error {
 	return nil
 }
 
-// expandVars replaces $var and ${var} in the input string using shell variables
-func (s *Shell) expandVars(input string) string {
-	// quick regex replacement
-	re := regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
-	return re.ReplaceAllStringFunc(input, func(m string) string {
-		// extract name
-		sub := ""
-		if strings.HasPrefix(m, "${") && strings.HasSuffix(m, "}") {
-			sub = m[2 : len(m)-1]
-		} else if strings.HasPrefix(m, "$") {
-			sub = m[1:]
-		}
-		if v, ok := s.vars[sub]; ok {
-			return v
-		}
-		return ""
-	})
-}
-
-// executeModule executes a Python module
+// executeModule executes a module through whichever runtime adapter claims it
 func (s *Shell) executeModule(cmdA []string, args []string) (map[string]interface{}, error) {
 	cmd := cmdA[0]
 
-	modulePath, err := s.loader.FindModule(cmd)
+	modulePath, adapter, err := s.loader.FindModule(cmd)
 	if err != nil {
 		s.printModuleNotFound(cmdA)
 		return nil, os.ErrNotExist
 	}
 
-	result, err := s.executor.Execute(cmd, args)
+	result, err := s.loader.InvokeWarm(modulePath, adapter, args)
 	if err != nil {
+		var invalidOutput *trex_modules.InvalidOutputError
+		if errors.As(err, &invalidOutput) {
+			s.printInvalidOutput(cmdA, modulePath, invalidOutput)
+			return nil, err
+		}
 		s.printExecutionError(cmdA, modulePath, err)
 		return nil, err
 	}
@@ -494,6 +738,22 @@ func (s *Shell) executeModule(cmdA []string, args []string) (map[string]interfac
 	return result, nil
 }
 
+// printInvalidOutput renders a multi-span report pointing at the exact
+// byte offset json reported, instead of a generic "invalid JSON" message.
+func (s *Shell) printInvalidOutput(cmd []string, modulePath string, invalid *trex_modules.InvalidOutputError) {
+	offset := int(invalid.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+
+	report := trex_errors.NewReport(trex_errors.SourceMap{modulePath + " (stdout)": invalid.Raw})
+	report.AddLabel(modulePath+" (stdout)", offset, offset+1, "module returned invalid JSON here", "")
+	report.WithHelp("module must print valid JSON to stdout and nothing else")
+	report.WithNote(fmt.Sprintf("json error: %v", invalid.Err))
+
+	fmt.Print(report.Format())
+}
+
 // ExecuteFile executes commands from a script file (one command per line)
 func (s *Shell) ExecuteFile(path string, verbose bool) {
 	data, err := os.ReadFile(path)
@@ -508,6 +768,21 @@ func (s *Shell) ExecuteFile(path string, verbose bool) {
 		fmt.Printf("Running script: %s\n", path)
 	}
 
+	prevScriptDir := s.currentScriptDir
+	if abs, err := filepath.Abs(path); err == nil {
+		s.currentScriptDir = filepath.Dir(abs)
+	}
+	defer func() { s.currentScriptDir = prevScriptDir }()
+
+	usePlan := s.planMode
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "#!trex plan" {
+		usePlan = true
+	}
+	if usePlan {
+		s.executePlan(lines, verbose)
+		return
+	}
+
 	for idx, raw := range lines {
 		line := strings.TrimSpace(raw)
 		if line == "" || strings.HasPrefix(line, "#") {
@@ -517,8 +792,17 @@ func (s *Shell) ExecuteFile(path string, verbose bool) {
 		if verbose == true {
 			fmt.Printf(" %d $ %s\n", idx+1, line)
 		}
-		s.history.Add(line)
-		if err := s.executeCommand(line, verbose); err != nil {
+		cwd, _ := os.Getwd()
+		start := time.Now()
+		err := s.executeCommand(line, verbose)
+		s.history.AddEntry(trex_utils.HistoryEntry{
+			Cmd:        line,
+			CWD:        cwd,
+			ExitCode:   boolToExitCode(err == nil),
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+
+		if err != nil {
 			// Write enhanced error info including file and line number
 			if home, herr := os.UserHomeDir(); herr == nil {
 				trexDir := filepath.Join(home, ".t-rex")
@@ -532,25 +816,70 @@ func (s *Shell) ExecuteFile(path string, verbose bool) {
 					f.WriteString(entry)
 				}
 			}
-			// Print a rich rust-style error with file/line/context
-			e := trex_errors.NewError(trex_errors.ErrorType("SCRIPT_ERROR"), "Error running script").WithLocation(path, idx+1).WithContext(line).WithHint("Check the command and module output for errors")
-			fmt.Print(e.Format())
+			// Print a structured diagnostic with file/line/context
+			d := trex_errors.NewDiagnostic(trex_errors.SeverityError, "error running script").
+				WithCode("SCRIPT_ERROR").
+				WithSource(path, string(data)).
+				WithPrimarySpan(path, idx+1, 1, idx+1, len(line)+1).
+				WithHelp("Check the command and module output for errors").
+				WithNote(fmt.Sprintf("error: %v", err))
+			s.renderer().Render(os.Stderr, d)
 			return
 		}
 	}
 }
 
+// handleHistory implements the `history` built-in: with no args it prints
+// the last 20 commands (or `history N` for the last N), and `history
+// --search` opens an atuin-like interactive fuzzy picker that reuses the
+// same FuzzyScore matcher as Ctrl-R.
+func (s *Shell) handleHistory(args []string) error {
+	if len(args) > 0 && args[0] == "--search" {
+		editor := trex_utils.NewLineEditor(s.history)
+		picked, err := editor.InteractivePick()
+		if err != nil {
+			return err
+		}
+		if picked == "" {
+			return nil
+		}
+		fmt.Println(picked)
+		return s.recordAndExecute(picked, false)
+	}
+
+	n := 20
+	if len(args) > 0 {
+		if parsed, err := strconv.Atoi(args[0]); err == nil {
+			n = parsed
+		}
+	}
+
+	for _, cmd := range s.history.GetLast(n) {
+		fmt.Println(cmd)
+	}
+	return nil
+}
+
+func boolToExitCode(success bool) int {
+	if success {
+		return 0
+	}
+	return 1
+}
+
 // handleForLoop matches and executes constructs like:
 // forloop 0..5 as $x do { echo "192.168.0.$x" }
+// forloop 0..255 as $i parallel 32 fail-fast do { ping 10.0.0.$i }
 func (s *Shell) handleForLoop(line string, verbose bool) (bool, error) {
-	re := regexp.MustCompile(`(?s)^\s*forloop\s+([^\s]+)\s+as\s+\$([A-Za-z_][A-Za-z0-9_]*)\s+do\s*\{(.*)\}\s*$`)
+	re := regexp.MustCompile(`(?s)^\s*forloop\s+([^\s]+)\s+as\s+\$([A-Za-z_][A-Za-z0-9_]*)\s+((?:(?:parallel(?:\s+\d+)?|fail-fast)\s+)*)do\s*\{(.*)\}\s*$`)
 	m := re.FindStringSubmatch(line)
 	if m == nil {
 		return false, nil
 	}
 	rangeExpr := m[1]
 	varName := m[2]
-	body := m[3]
+	parallelN, failFast := parseLoopModifiers(m[3])
+	body := m[4]
 
 	var values []string
 	if strings.Contains(rangeExpr, "..") {
@@ -593,24 +922,15 @@ func (s *Shell) handleForLoop(line string, verbose bool) (bool, error) {
 		}
 	}
 
-	for _, val := range values {
-		s.vars[varName] = val
-		for _, cmd := range cmds {
-			expanded := s.expandVars(cmd)
-			if err := s.executeCommand(expanded, verbose); err != nil {
-				return true, err
-			}
-		}
-	}
-	// remove loop variable
-	delete(s.vars, varName)
-	return true, nil
+	err := s.runLoopBody(values, varName, cmds, verbose, parallelN, failFast)
+	return true, err
 }
 
 // handleForeach handles constructs like:
 // foreach "sha256"|"sha512" as $x do { echo $x }
+// foreach [1,2,3,4] as $x parallel 8 do { nmap $x }
 func (s *Shell) handleForeach(line string, verbose bool) (bool, error) {
-	re := regexp.MustCompile(`(?s)^\s*foreach\s+(.+?)\s+as\s+\$([A-Za-z_][A-Za-z0-9_]*)\s+do\s*\{(.*)\}\s*$`)
+	re := regexp.MustCompile(`(?s)^\s*foreach\s+(.+?)\s+as\s+\$([A-Za-z_][A-Za-z0-9_]*)\s+((?:(?:parallel(?:\s+\d+)?|fail-fast)\s+)*)do\s*\{(.*)\}\s*$`)
 	m := re.FindStringSubmatch(line)
 	if m == nil {
 		return false, nil
@@ -618,7 +938,8 @@ func (s *Shell) handleForeach(line string, verbose bool) (bool, error) {
 
 	listExpr := strings.TrimSpace(m[1])
 	varName := m[2]
-	body := m[3]
+	parallelN, failFast := parseLoopModifiers(m[3])
+	body := m[4]
 
 	var items []string
 	// array literal
@@ -649,136 +970,163 @@ func (s *Shell) handleForeach(line string, verbose bool) (bool, error) {
 		}
 	}
 
-	for _, it := range items {
-		s.vars[varName] = it
-		for _, cmd := range cmds {
-			expanded := s.expandVars(cmd)
-			if err := s.executeCommand(expanded, verbose); err != nil {
-				return true, err
+	err := s.runLoopBody(items, varName, cmds, verbose, parallelN, failFast)
+	return true, err
+}
+
+// parseLoopModifiers reads an optional "parallel [N]" and/or "fail-fast"
+// clause out of a forloop/foreach header (in either order; either, both,
+// or neither may be present). parallelN is 0 when no "parallel" clause
+// was given, meaning "run sequentially".
+func parseLoopModifiers(raw string) (parallelN int, failFast bool) {
+	fields := strings.Fields(raw)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "parallel":
+			parallelN = globalJobs
+			if i+1 < len(fields) {
+				if n, err := strconv.Atoi(fields[i+1]); err == nil {
+					parallelN = n
+					i++
+				}
 			}
+		case "fail-fast":
+			failFast = true
 		}
 	}
-	delete(s.vars, varName)
-	return true, nil
+	return parallelN, failFast
 }
 
-// printRustStyleError prints a diagnostic message in a rustc-like style
-// using only standard library + ANSI escape codes (no external dependencies)
+// LoopError is one failed iteration of a parallel forloop/foreach, keyed
+// by its position and the loop-variable value it ran with.
+type LoopError struct {
+	Index int
+	Value string
+	Err   error
+}
 
-// ANSI color codes
-const (
-	reset  = "\x1b[0m"
-	bold   = "\x1b[1m"
-	red    = "\x1b[31m"
-	yellow = "\x1b[33m"
-	cyan   = "\x1b[36m"
-	green  = "\x1b[32m"
-	gray   = "\x1b[90m"
-)
+func (e LoopError) Error() string {
+	return fmt.Sprintf("[%d] %s: %v", e.Index, e.Value, e.Err)
+}
 
-// printRustStyleError prints a diagnostic message in a rustc-like style
-func printRustStyleError(
-	level string, // "ERROR", "WARNING", "NOTE"
-	title string, // e.g. "module not found"
-	location string, // "file.trex:9:5" or "<interactive>" or ""
-	codeContext string, // the offending source line (or "")
-	underlineStart int, // 0-based column
-	underlineLen int, // how many characters to underline
-	message string, // main error message
-	hint string, // optional hint
-	notes ...string, // additional notes
-) {
-	var levelColor string
-	switch strings.ToUpper(level) {
-	case "ERROR":
-		levelColor = red
-	case "WARNING":
-		levelColor = yellow
-	case "NOTE":
-		levelColor = cyan
-	default:
-		levelColor = red
-	}
+// LoopErrors aggregates every failed iteration of a parallel
+// forloop/foreach into a single error.
+type LoopErrors []LoopError
 
-	// ────────────────────────────────────────────────
-	// Header
-	// ────────────────────────────────────────────────
+func (e LoopErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, le := range e {
+		msgs[i] = le.Error()
+	}
+	return fmt.Sprintf("%d iteration(s) failed:\n%s", len(e), strings.Join(msgs, "\n"))
+}
 
-	// Location (with nicer spacing)
-	header := fmt.Sprintf("<%s%s%s%s> %s",
-		bold, levelColor, level, reset, bold+title+reset)
+// runLoopBody runs cmds once per value, substituting it for varName each
+// time, and backs both handleForLoop and handleForeach.
+//
+// With parallelN <= 1 it runs sequentially against the shell's own
+// vars/stdout, exactly as before. With parallelN > 1, each iteration runs
+// on a worker pool of that size against its own forked Shell (its own
+// vars map, via forkVars, and its own output buffer) so iterations never
+// race on shared state; results are then flushed to the real stdout in
+// submission order, so a parallel sweep reads the same as a sequential
+// one would have. Per-iteration errors are collected into a LoopErrors
+// rather than aborting the batch, unless failFast is set, in which case
+// no further iterations are dispatched once the first one fails (ones
+// already in flight still finish).
+func (s *Shell) runLoopBody(values []string, varName string, cmds []string, verbose bool, parallelN int, failFast bool) error {
+	if parallelN <= 1 {
+		for _, val := range values {
+			s.vars[varName] = val
+			for _, cmd := range cmds {
+				expanded, err := s.expandVars(cmd)
+				if err != nil {
+					return err
+				}
+				if err := s.executeCommand(expanded, verbose); err != nil {
+					return err
+				}
+			}
+		}
+		delete(s.vars, varName)
+		return nil
+	}
 
-	if location != "" {
-		fmt.Fprintf(os.Stderr, " %s-->%s %s %s\n", cyan, reset, location, header)
-	} else {
-		location = "entry:repl"
-		fmt.Fprintf(os.Stderr, "%s--->%s %s %s\n", cyan, reset, location, header)
+	type iterResult struct {
+		buf bytes.Buffer
+		err error
 	}
 
-	// Separator line
-	//fmt.Fprintf(os.Stderr, " %s│%s\n", cyan, reset)
+	results := make([]iterResult, len(values))
+	sem := make(chan struct{}, parallelN)
+	var wg sync.WaitGroup
+	var stopped atomic.Bool
 
-	// Code context + underline
-	if codeContext != "" {
-		// Show the source line
-		fmt.Fprintf(os.Stderr, " %s│%s\n", cyan, reset)
-		if location == "entry:repl" {
-			fmt.Fprintf(os.Stderr, "%s0│%s %s\n", cyan, reset, codeContext)
-		} else {
-			fmt.Fprintf(os.Stderr, " %s│%s %s\n", cyan, reset, codeContext)
-		}
-
-		// Underline (only if meaningful)
-		if underlineLen > 0 && underlineStart >= 0 {
-			spaces := strings.Repeat("", underlineStart)
-			underline := strings.Repeat("^", underlineLen) // ^ is more common in modern rustc
-			fmt.Fprintf(os.Stderr, " %s│%s %s%s%s %s\n",
-				cyan, reset,
-				spaces,
-				red+bold+underline+reset,
-				" "+message, reset,
-			)
-		} else {
-			// No underline → message right below line
-			fmt.Fprintf(os.Stderr, " %s│%s  %s\n", cyan, reset, message)
+	for i, val := range values {
+		if failFast && stopped.Load() {
+			break
 		}
-	} else {
-		// No code → just message after separator
-		fmt.Fprintf(os.Stderr, " %s│%s\n", cyan, reset)
-		fmt.Fprintf(os.Stderr, " %s│%s %s\n", cyan, reset, message)
-	}
 
-	// Hint (if any)
-	if hint != "" {
-		fmt.Fprintf(os.Stderr, " %s│%s\n", cyan, reset)
-		fmt.Fprintf(os.Stderr, " %s│%s %shint:%s %s\n", cyan, reset, bold, reset, hint)
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, val string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if failFast && stopped.Load() {
+				return
+			}
+
+			child := s.forkVars(varName, val)
+			child.outWriter = &results[i].buf
+
+			for _, cmd := range cmds {
+				expanded, err := child.expandVars(cmd)
+				if err != nil {
+					results[i].err = err
+					break
+				}
+				if err := child.executeCommand(expanded, verbose); err != nil {
+					results[i].err = err
+					break
+				}
+			}
+
+			if results[i].err != nil && failFast {
+				stopped.Store(true)
+			}
+		}(i, val)
 	}
 
-	// Notes
-	for _, note := range notes {
-		fmt.Fprintf(os.Stderr, " %s│%s %snote:%s %s\n", cyan, reset, bold, reset, note)
+	wg.Wait()
+
+	var failures LoopErrors
+	for i, r := range results {
+		io.Copy(s.out(), &r.buf)
+		if r.err != nil {
+			failures = append(failures, LoopError{Index: i, Value: values[i], Err: r.err})
+		}
 	}
 
-	fmt.Fprintln(os.Stderr)
+	if len(failures) > 0 {
+		return failures
+	}
+	return nil
 }
 
 // printModuleNotFound – wrapper for module-not-found case
 func (s *Shell) printModuleNotFound(cmd []string) {
-	// You can improve this later by reading context from s.currentScript etc.
-	// For now — keeping it simple as per original signature limitation
-
-	printRustStyleError(
-		"err_module_not_found",
-		"module not found",
-		"entry#repl",           // location
-		strings.Join(cmd, " "), // code context
-		0, len(cmd[0]),
-		fmt.Sprintf("cannot find module %s'%s' %s", bold, cmd[0], reset),
-		fmt.Sprintf("expected to find %s.py / %s.json / %s.yaml (or similar) in the modules directory", cmd[0], cmd[0], cmd[0]),
-		fmt.Sprintf("current search path: %s", s.moduleDir),
-		fmt.Sprintf("run %sls -la %s%s to see available modules", bold, s.moduleDir, reset),
-	)
-	//println(len(strings.Split(cmd, " ")[0]))
+	line := strings.Join(cmd, " ")
+	d := trex_errors.NewDiagnostic(trex_errors.SeverityError, fmt.Sprintf("cannot find module '%s'", cmd[0])).
+		WithCode("module_not_found").
+		WithSource("<interactive>", line).
+		WithPrimarySpan("<interactive>", 1, 1, 1, len(cmd[0])+1).
+		WithHelp(fmt.Sprintf("expected to find %s.py / %s.json / %s.yaml (or similar) in the modules directory", cmd[0], cmd[0], cmd[0])).
+		WithNote(fmt.Sprintf("current search path: %s", s.moduleDir)).
+		WithNote(fmt.Sprintf("run ls -la %s to see available modules", s.moduleDir))
+
+	s.renderer().Render(os.Stderr, d)
 }
 
 // printExecutionError – wrapper for module runtime / output errors
@@ -792,18 +1140,15 @@ func (s *Shell) printExecutionError(cmd []string, modulePath string, err error)
 		}
 	}
 
-	printRustStyleError(
-		"ERROR",
-		"module execution failed",
-		relPath, // using module file as "location" for now
-		"",      // no source line context (would need shell state)
-		0, 0,
-		fmt.Sprintf("%s: %v", cmd[0], err),
-		"module must print **valid JSON** to stdout and nothing else",
-		fmt.Sprintf("no stray prints, debug output, tracebacks, or syntax errors allowed"),
-		fmt.Sprintf("full path: %s", modulePath),
-		"check Python syntax, imports, and use json.dumps(...) correctly",
-	)
+	d := trex_errors.NewDiagnostic(trex_errors.SeverityError, fmt.Sprintf("%s: %v", cmd[0], err)).
+		WithCode("module_execution_failed").
+		WithPrimarySpan(relPath, 0, 0, 0, 0).
+		WithHelp("module must print valid JSON to stdout and nothing else").
+		WithNote("no stray prints, debug output, tracebacks, or syntax errors allowed").
+		WithNote(fmt.Sprintf("full path: %s", modulePath)).
+		WithNote("check Python syntax, imports, and use json.dumps(...) correctly")
+
+	s.renderer().Render(os.Stderr, d)
 
 	// ─── Append structured log entry ─────────────────────────────────────
 	home, _ := os.UserHomeDir()
@@ -840,8 +1185,13 @@ func (s *Shell) printResult(result map[string]interface{}) {
 		return
 	}
 
+	if output, exists := result["output"]; exists {
+		s.lastOutput = fmt.Sprintf("%v", output)
+	}
+
 	prettyPrint := false
 	tablePrint := false
+	rawPrint := false
 
 	if pp, exists := result["__pretty_print"]; exists {
 		prettyPrint = pp.(bool)
@@ -849,28 +1199,78 @@ func (s *Shell) printResult(result map[string]interface{}) {
 	if tt, exists := result["__table_print"]; exists {
 		tablePrint = tt.(bool)
 	}
+	if rp, exists := result["__raw_print"]; exists {
+		rawPrint = rp.(bool)
+	}
 
-	fmt.Println()
+	out := s.out()
+	fmt.Fprintln(out)
 
-	if tablePrint {
+	if rawPrint {
 		if output, exists := result["output"]; exists {
-			fmt.Print(trex_utils.TablePrint(output))
+			fmt.Fprint(out, output)
+		}
+	} else if tablePrint {
+		if output, exists := result["output"]; exists {
+			opts := trex_utils.DefaultTableOptions()
+			if noColor, ok := result["__table_no_color"].(bool); ok {
+				opts.NoColor = noColor
+			}
+			if columns, ok := result["__table_columns"].([]string); ok {
+				rows, _ := output.([]interface{})
+				fmt.Fprint(out, trex_utils.TablePrintOrdered(rows, columns, opts))
+			} else {
+				fmt.Fprint(out, trex_utils.TablePrintWithOptions(output, opts))
+			}
 		}
 	} else if prettyPrint {
 		if output, exists := result["output"]; exists {
-			fmt.Print(trex_utils.PrettyPrint(output))
+			fmt.Fprint(out, trex_utils.PrettyPrint(output))
+		}
+	} else if s.outputTemplate != "" {
+		tpl, err := template.Compile(s.outputTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "output_template: %v\n", err)
+			if data, merr := json.MarshalIndent(result, "", "  "); merr == nil {
+				fmt.Fprintln(out, string(data))
+			}
+		} else if rendered, err := tpl.Render(result); err != nil {
+			fmt.Fprintf(os.Stderr, "output_template: %v\n", err)
+		} else {
+			fmt.Fprint(out, rendered)
 		}
 	} else {
 		// Print as formatted JSON
 		if data, err := json.MarshalIndent(result, "", "  "); err == nil {
-			fmt.Println(string(data))
+			fmt.Fprintln(out, string(data))
 		}
 	}
 
-	fmt.Println()
+	fmt.Fprintln(out)
 }
 
-// loadConfig loads configuration from .trexrc
+// loadTemplateSource resolves a `template` pipeline stage's argument: a
+// literal template string, or "@path" to load one from disk (relative
+// paths resolve against the running script's directory, if any).
+func loadTemplateSource(arg string, scriptDir string) (string, error) {
+	if !strings.HasPrefix(arg, "@") {
+		return arg, nil
+	}
+
+	path := arg[1:]
+	if !filepath.IsAbs(path) && scriptDir != "" {
+		path = filepath.Join(scriptDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("template: failed to read %s: %w", arg, err)
+	}
+	return string(data), nil
+}
+
+// loadConfig loads and validates configuration from .trexrc against
+// trex_config.DefaultSchema, keeping the parsed trex_config.Document on
+// the shell so "config get/set/list" can read and persist through it.
 func loadConfig(s *Shell) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -880,64 +1280,138 @@ func loadConfig(s *Shell) {
 	trexDir := filepath.Join(homeDir, ".t-rex")
 	os.MkdirAll(trexDir, 0755)
 
-	configPath := filepath.Join(trexDir, ".trexrc")
-	data, err := os.ReadFile(configPath)
+	s.configPath = filepath.Join(trexDir, ".trexrc")
+	data, err := os.ReadFile(s.configPath)
 	if err != nil {
 		// Create default config if doesn't exist
-		createDefaultConfig(configPath)
-		data, _ = os.ReadFile(configPath)
-
+		createDefaultConfig(s.configPath)
+		data, _ = os.ReadFile(s.configPath)
 	}
 
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
+	doc, errs := trex_config.Parse(data, trex_config.DefaultSchema())
+	s.configDoc = doc
+	for _, verr := range errs {
+		ve, ok := verr.(*trex_config.ValidationError)
+		if !ok {
 			continue
 		}
+		d := trex_errors.NewDiagnostic(trex_errors.SeverityWarning, ve.Message).
+			WithCode("config_invalid_value").
+			WithSource(s.configPath, string(data)).
+			WithPrimarySpan(s.configPath, ve.Line, 1, ve.Line, len(strings.TrimRight(string(data), "\n"))+1).
+			WithHelp(fmt.Sprintf("run `trex config set %s <value>` to fix it", ve.Key))
+		s.renderer().Render(os.Stderr, d)
+	}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			val := strings.TrimSpace(parts[1])
+	// Schema keys absent from .trexrc (e.g. left commented out by the
+	// wizard) still take an env override when one is set.
+	entries := doc.List()
+	var envOnly []string
+	for key := range trex_config.DefaultSchema() {
+		if _, present := doc.Get(key); !present && os.Getenv(envKeyFor(key)) != "" {
+			envOnly = append(envOnly, key)
+		}
+	}
+	sort.Strings(envOnly)
+	for _, key := range envOnly {
+		entries = append(entries, trex_config.Entry{Key: key})
+	}
 
-			if key == "use_colors" && val == "false" {
-				s.useColors = false
-			}
-			if key == "prompt_symbol" {
-				s.promptTemplate = val
-			}
-			if key == "prompt_template" {
-				s.promptTemplate = val
-			}
-		} else {
-			s.executeCommand(line, false)
+	s.settings = make(map[string]string)
+	for _, entry := range entries {
+		val := resolveSetting(entry.Key, entry.Value)
+		s.settings[entry.Key] = val
+		os.Setenv(envKeyFor(entry.Key), val)
+
+		if entry.Key == "use_colors" && val == "false" {
+			s.useColors = false
+		}
+		if entry.Key == "prompt_symbol" {
+			s.promptTemplate = val
 		}
-		//fmt.Println(line)
+		if entry.Key == "prompt_template" {
+			s.promptTemplate = val
+		}
+		if entry.Key == "output_template" {
+			s.outputTemplate = val
+		}
+	}
+
+	for _, cmd := range doc.Commands() {
+		s.executeCommand(cmd, false)
 	}
 }
 
-// createDefaultConfig creates a default .trexrc file
-func createDefaultConfig(configPath string) {
-	config := `# T-Rex Shell Configuration
-module_paths=~/.t-rex/modules
-use_colors=true
-theme=default
-history_enabled=true
-history_size=1000
+// handleConfigCommand implements `trex config get/set/list`, reading
+// and writing through the same schema-validated Document loadConfig
+// parsed, so interactive edits get the same validation as the file.
+func (s *Shell) handleConfigCommand(args []string) error {
+	if s.configDoc == nil {
+		return fmt.Errorf("config: no .trexrc loaded")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: config get <key> | config set <key> <value> | config list")
+	}
 
-# Prompt customization - use format: prompt_template=%u@%h:%D❯
-# %u = username
-# %h = hostname
-# %w = full working directory
-# %d = full working directory (same as %w)
-# %D = working directory basename only
-# %~ = home directory relative path
+	switch args[0] {
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: config get <key>")
+		}
+		val, ok := s.configDoc.Get(args[1])
+		if !ok {
+			return fmt.Errorf("config: %s is not set", args[1])
+		}
+		fmt.Println(val)
+		return nil
 
-prompt_symbol=❯
-prompt_template=❯
-prompt_color=cyan
-python_executable=python3
-`
-	os.WriteFile(configPath, []byte(config), 0644)
+	case "set":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: config set <key> <value>")
+		}
+		key := args[1]
+		val := strings.Join(args[2:], " ")
+		if err := s.configDoc.Set(key, val); err != nil {
+			return err
+		}
+		if err := os.WriteFile(s.configPath, []byte(s.configDoc.String()), 0644); err != nil {
+			return fmt.Errorf("config: failed to write %s: %w", s.configPath, err)
+		}
+		fmt.Printf("%s=%s\n", key, val)
+		return nil
+
+	case "list":
+		for _, entry := range s.configDoc.List() {
+			fmt.Printf("%s=%s\n", entry.Key, entry.Value)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("usage: config get <key> | config set <key> <value> | config list")
+	}
+}
+
+// envKeyFor maps a .trexrc key to the environment variable that overrides
+// it, e.g. "prompt_template" -> "TREX_PROMPT_TEMPLATE".
+func envKeyFor(key string) string {
+	return "TREX_" + strings.ToUpper(key)
 }
+
+// resolveSetting applies the env-override precedence (env var > .trexrc
+// value > built-in default) for a single .trexrc key. fileVal is the
+// .trexrc value, or "" for a schema key absent from .trexrc, which
+// loadConfig only resolves when its env var is set; otherwise the Shell's
+// built-in default stays in place. Every key is resolved this same way,
+// so keys added to .trexrc later pick up overrides with no extra code.
+func resolveSetting(key, fileVal string) string {
+	if env := os.Getenv(envKeyFor(key)); env != "" {
+		return env
+	}
+	return fileVal
+}
+
+// createDefaultConfig is implemented in config_wizard.go: on a first run
+// it prompts the user with a TUI wizard, unless --defaults was passed or
+// the environment already overrides every prompted key (see
+// shouldPromptConfig), in which case it writes the hardcoded defaults.
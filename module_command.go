@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+)
+
+// handleModuleCommand implements `trex module add/list/remove/update`,
+// managing the registry of module_paths sources (local directories, git
+// remotes, HTTP archives) that back s.loader's search path.
+func (s *Shell) handleModuleCommand(args []string) error {
+	if s.registry == nil {
+		return fmt.Errorf("module: registry not initialized")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: module add <source> | module list | module remove <source> | module update")
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: module add <source>")
+		}
+		if err := s.registry.Add(args[1]); err != nil {
+			return err
+		}
+		for _, err := range s.registry.Update() {
+			fmt.Println("warning:", err)
+		}
+		for _, path := range s.registry.ResolvedPaths() {
+			s.loader.AddPath(path)
+		}
+		fmt.Printf("added module source: %s\n", args[1])
+		return nil
+
+	case "list":
+		for _, src := range s.registry.List() {
+			fmt.Printf("%s\t%s\n", src.Kind, src.Location)
+		}
+		manifests, errs := s.registry.Discover()
+		for _, m := range manifests {
+			fmt.Printf("  - %s (%s) -> %s\n", m.Name, m.Version, m.Entrypoint)
+		}
+		for _, err := range errs {
+			fmt.Println("warning:", err)
+		}
+		return nil
+
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: module remove <source>")
+		}
+		if err := s.registry.Remove(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("removed module source: %s\n", args[1])
+		return nil
+
+	case "update":
+		errs := s.registry.Update()
+		for _, err := range errs {
+			fmt.Println("warning:", err)
+		}
+		fmt.Println("module sources updated")
+		return nil
+
+	default:
+		return fmt.Errorf("usage: module add <source> | module list | module remove <source> | module update")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"trex_plan"
+	"trex_utils"
+)
+
+// forkAll returns a shallow copy of the shell with its own vars map
+// (seeded from the parent's), used by executePlan so each DAG node runs
+// against a private copy of shell state until its wave's writes are
+// merged back. See also forkVars, which forks for a single loop variable.
+func (s *Shell) forkAll() *Shell {
+	child := *s
+	child.vars = make(map[string]string, len(s.vars))
+	for k, v := range s.vars {
+		child.vars[k] = v
+	}
+	return &child
+}
+
+// varsSnapshot renders vars as a deterministic string, for hashing into a
+// cache key (map iteration order isn't stable, so this sorts by key).
+func varsSnapshot(vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + vars[k]
+	}
+	return strings.Join(parts, "\n")
+}
+
+// executePlan runs a script through the dependency-DAG scheduler instead
+// of running it line by line: build the graph, then run each wave's
+// nodes concurrently (capped at globalJobs, the same default used by
+// parallel forloop/foreach), merging each node's variable writes back
+// into s.vars once its wave finishes so later waves see them. A node
+// whose command text and variable inputs exactly match a previous run is
+// served from ~/.t-rex/cache instead of re-executing, unless it writes a
+// variable: such nodes always run, so their writes reach s.vars.
+func (s *Shell) executePlan(lines []string, verbose bool) {
+	graph := trex_plan.BuildGraph(lines)
+
+	if verbose {
+		fmt.Print(graph.Dump())
+	}
+
+	for _, wave := range graph.Waves() {
+		type outcome struct {
+			buf    bytes.Buffer
+			err    error
+			child  *Shell
+			cached bool
+		}
+		outcomes := make(map[int]*outcome, len(wave))
+
+		sem := make(chan struct{}, globalJobs)
+		var wg sync.WaitGroup
+
+		for _, id := range wave {
+			node := graph.Nodes[id]
+			o := &outcome{}
+			outcomes[id] = o
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(raw string, writes []string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				child := s.forkAll()
+				child.outWriter = &o.buf
+				o.child = child
+
+				key := trex_plan.CacheKey(raw, varsSnapshot(child.vars))
+				if text, ok := trex_plan.Get(key); ok && len(writes) == 0 {
+					o.buf.WriteString(text)
+					o.cached = true
+					return
+				}
+
+				if err := child.executeCommand(raw, verbose); err != nil {
+					o.err = err
+					return
+				}
+				trex_plan.Set(key, o.buf.String())
+			}(node.Raw, node.Writes)
+		}
+
+		wg.Wait()
+
+		for _, id := range wave {
+			o := outcomes[id]
+			io.Copy(s.out(), &o.buf)
+			if o.err != nil {
+				trex_utils.PrintError(o.err.Error())
+				return
+			}
+			for _, name := range graph.Nodes[id].Writes {
+				if v, ok := o.child.vars[name]; ok {
+					s.vars[name] = v
+				}
+			}
+		}
+	}
+}
+
+// DumpPlan prints the DAG `trex --plan-dump` would run path through,
+// without actually executing anything.
+func (s *Shell) DumpPlan(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		trex_utils.PrintError("Failed to read script: " + err.Error())
+		return
+	}
+
+	lines := strings.Split(string(data), "\n")
+	graph := trex_plan.BuildGraph(lines)
+
+	fmt.Print(graph.DumpWithCacheCheck(func(n *trex_plan.Node) bool {
+		key := trex_plan.CacheKey(n.Raw, varsSnapshot(s.vars))
+		return trex_plan.Has(key)
+	}))
+}
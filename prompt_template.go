@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode"
+
+	"trex_utils"
+)
+
+// promptContext is what a {{ }} prompt template renders against.
+type promptContext struct {
+	User     string
+	Host     string
+	Cwd      string
+	HomeRel  string // cwd with $HOME collapsed to "~"
+	Dir      string // basename of cwd
+	ExitCode int
+	Duration time.Duration
+}
+
+// promptFuncMap is the helper library available to prompt templates,
+// analogous to tmpl's: case converters, env/time lookups, and a few
+// shell-specific helpers (git branch, exit code, duration, venv).
+func promptFuncMap(s *Shell) texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		"upper":       strings.ToUpper,
+		"lower":       strings.ToLower,
+		"title":       strings.Title,
+		"snake":       toSnakeCase,
+		"kebab":       toKebabCase,
+		"pascal":      toPascalCase,
+		"camel":       toCamelCase,
+		"env":         os.Getenv,
+		"time":        func(layout string) string { return time.Now().Format(layout) },
+		"trim_prefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trim_suffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"git_branch":  gitBranch,
+		"exit_code":   func() int { return s.lastExitCode },
+		"duration":    func() string { return s.lastDuration.Round(time.Millisecond).String() },
+		"venv":        venvName,
+	}
+}
+
+// gitBranch returns the current branch name for the cwd, or "" outside a
+// git repo (so templates can e.g. `{{if git_branch}} on {{git_branch}}{{end}}`).
+func gitBranch() string {
+	out, err := exec.Command("git", "symbolic-ref", "--short", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// venvName returns the basename of $VIRTUAL_ENV, or "" if not in one.
+func venvName() string {
+	v := os.Getenv("VIRTUAL_ENV")
+	if v == "" {
+		return ""
+	}
+	return filepath.Base(v)
+}
+
+func toSnakeCase(s string) string  { return toDelimitedCase(s, '_') }
+func toKebabCase(s string) string  { return toDelimitedCase(s, '-') }
+
+func toDelimitedCase(s string, sep rune) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteRune(sep)
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else if r == ' ' || r == '-' || r == '_' {
+			b.WriteRune(sep)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func toPascalCase(s string) string {
+	var b strings.Builder
+	nextUpper := true
+	for _, r := range s {
+		if r == ' ' || r == '-' || r == '_' {
+			nextUpper = true
+			continue
+		}
+		if nextUpper {
+			b.WriteRune(unicode.ToUpper(r))
+			nextUpper = false
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func toCamelCase(s string) string {
+	pascal := toPascalCase(s)
+	if pascal == "" {
+		return ""
+	}
+	r := []rune(pascal)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// buildPromptContext gathers the values prompt templates and the legacy
+// %-token renderer both draw from.
+func (s *Shell) buildPromptContext() promptContext {
+	ctx := promptContext{ExitCode: s.lastExitCode, Duration: s.lastDuration}
+
+	if currentUser, err := user.Current(); err == nil {
+		ctx.User = currentUser.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		ctx.Host = host
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		ctx.Cwd = cwd
+		ctx.Dir = filepath.Base(cwd)
+		ctx.HomeRel = cwd
+		if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(cwd, home) {
+			ctx.HomeRel = "~" + strings.TrimPrefix(cwd, home)
+		}
+	}
+
+	return ctx
+}
+
+// renderPrompt renders s.promptTemplate: full Go text/template syntax if
+// it starts with "{{" (compiled once and cached on the Shell), otherwise
+// the legacy %u/%h/%D-style renderer. A template that fails to parse or
+// execute falls back to the legacy renderer rather than breaking the
+// shell's prompt.
+func (s *Shell) renderPrompt() string {
+	tmplSrc := s.promptTemplate
+
+	if strings.HasPrefix(strings.TrimSpace(tmplSrc), "{{") {
+		if s.promptTmpl == nil || s.promptTmplSrc != tmplSrc {
+			t, err := texttemplate.New("prompt").Funcs(promptFuncMap(s)).Parse(tmplSrc)
+			if err != nil {
+				trex_utils.PrintError("prompt_template: " + err.Error())
+				return s.legacyPrompt()
+			}
+			s.promptTmpl = t
+			s.promptTmplSrc = tmplSrc
+		}
+
+		var b strings.Builder
+		if err := s.promptTmpl.Execute(&b, s.buildPromptContext()); err != nil {
+			trex_utils.PrintError("prompt_template: " + err.Error())
+			return s.legacyPrompt()
+		}
+		return b.String() + " "
+	}
+
+	return s.legacyPrompt()
+}
+
+// legacyPrompt renders s.promptTemplate through the original %-token
+// expander, colored the way BuildPrompt always has been.
+func (s *Shell) legacyPrompt() string {
+	symbol := s.promptTemplate
+	if symbol == "" {
+		symbol = "❯"
+	}
+	return string(s.promptColor) + trex_utils.ExpandPrompt(symbol) + string(trex_utils.Reset) + " "
+}
+
+// handlePromptCommand implements `trex prompt test <template>`, which
+// renders a template against the current context without starting the
+// interactive shell — useful for iterating on a prompt_template value.
+func (s *Shell) handlePromptCommand(args []string) error {
+	if len(args) < 2 || args[0] != "test" {
+		return fmt.Errorf("usage: prompt test <template>")
+	}
+
+	tmplSrc := strings.Join(args[1:], " ")
+	prev := s.promptTemplate
+	s.promptTemplate = tmplSrc
+	s.promptTmpl = nil
+	rendered := s.renderPrompt()
+	s.promptTemplate = prev
+
+	fmt.Print(rendered)
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,154 @@
+package trex_config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError is a single line-numbered problem found while parsing
+// a .trexrc document against the schema.
+type ValidationError struct {
+	Line    int // 1-based
+	Key     string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line <= 0 {
+		return fmt.Sprintf("%s: %s", e.Key, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Key, e.Line, e.Message)
+}
+
+// line is one physical line of a .trexrc file: either a key=value
+// setting, or "other" (blank, comment, or a non-setting command line
+// t-rex also allows in its config).
+type line struct {
+	raw       string
+	isSetting bool
+	isCommand bool // a bare line that isn't a comment or key=value (executed as a shell command)
+	key       string
+	value     string
+}
+
+// Document is a parsed .trexrc file that remembers comments and key
+// order, so editing a value through Set and writing it back out with
+// String doesn't disturb the rest of the file.
+type Document struct {
+	schema Schema
+	lines  []line
+}
+
+// Parse reads a .trexrc file against schema, validating every key=value
+// line it finds. It returns the parsed Document even when errors are
+// present (so callers can still use whatever did parse), alongside every
+// validation error found, each carrying its 1-based line number.
+func Parse(data []byte, schema Schema) (*Document, []error) {
+	d := &Document{schema: schema}
+	var errs []error
+
+	rawLines := strings.Split(string(data), "\n")
+	for i, raw := range rawLines {
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			d.lines = append(d.lines, line{raw: raw})
+			continue
+		}
+
+		parts := strings.SplitN(trimmed, "=", 2)
+		if len(parts) != 2 {
+			// Not a key=value line (e.g. a bare command); keep it verbatim.
+			d.lines = append(d.lines, line{raw: raw, isCommand: true})
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		if spec, ok := schema[key]; ok {
+			if err := spec.Validate(val); err != nil {
+				errs = append(errs, &ValidationError{Line: i + 1, Key: key, Message: err.Error()})
+			}
+		}
+
+		d.lines = append(d.lines, line{raw: raw, isSetting: true, key: key, value: val})
+	}
+
+	return d, errs
+}
+
+// Get returns the value stored for key and whether it was present.
+func (d *Document) Get(key string) (string, bool) {
+	for _, l := range d.lines {
+		if l.isSetting && l.key == key {
+			return l.value, true
+		}
+	}
+	if spec, ok := d.schema[key]; ok {
+		return spec.Default, false
+	}
+	return "", false
+}
+
+// Set validates val against key's schema entry (if any) and stores it,
+// updating the existing line in place if key is already present, or
+// appending a new line otherwise. Unknown keys (not in the schema) are
+// still accepted, matching the schema's job of validating known keys
+// without rejecting forward-compatible ones.
+func (d *Document) Set(key, val string) error {
+	if spec, ok := d.schema[key]; ok {
+		if err := spec.Validate(val); err != nil {
+			return &ValidationError{Key: key, Message: err.Error()}
+		}
+	}
+
+	for i, l := range d.lines {
+		if l.isSetting && l.key == key {
+			d.lines[i] = line{raw: key + "=" + val, isSetting: true, key: key, value: val}
+			return nil
+		}
+	}
+
+	d.lines = append(d.lines, line{raw: key + "=" + val, isSetting: true, key: key, value: val})
+	return nil
+}
+
+// Entry is one resolved key=value pair, as returned by List.
+type Entry struct {
+	Key   string
+	Value string
+}
+
+// List returns every setting in the document, in file order.
+func (d *Document) List() []Entry {
+	var entries []Entry
+	for _, l := range d.lines {
+		if l.isSetting {
+			entries = append(entries, Entry{Key: l.key, Value: l.value})
+		}
+	}
+	return entries
+}
+
+// Commands returns every bare (non-comment, non-setting) line, in file
+// order — .trexrc allows plain shell commands alongside settings.
+func (d *Document) Commands() []string {
+	var cmds []string
+	for _, l := range d.lines {
+		if l.isCommand {
+			cmds = append(cmds, strings.TrimSpace(l.raw))
+		}
+	}
+	return cmds
+}
+
+// String renders the document back to .trexrc text, preserving comments
+// and key order exactly as parsed (plus any keys appended by Set).
+func (d *Document) String() string {
+	var b strings.Builder
+	for _, l := range d.lines {
+		b.WriteString(l.raw)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
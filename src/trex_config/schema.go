@@ -0,0 +1,81 @@
+// Package trex_config defines the typed schema for .trexrc keys and a
+// parser/writer that validates against it while preserving comments and
+// key ordering, so "trex config set" round-trips a hand-edited file.
+package trex_config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldType is the type a schema entry's value must satisfy.
+type FieldType int
+
+const (
+	TypeString FieldType = iota
+	TypeInt
+	TypeBool
+	TypeEnum
+	TypePath
+)
+
+// FieldSpec describes one recognized .trexrc key.
+type FieldSpec struct {
+	Key     string
+	Type    FieldType
+	Options []string // valid values, TypeEnum only
+	Default string
+}
+
+// Schema maps a key to its FieldSpec.
+type Schema map[string]FieldSpec
+
+// DefaultSchema is the set of .trexrc keys t-rex understands today. New
+// keys should be added here; the loader, and `config get/set/list`,
+// validate and discover options against this one place.
+func DefaultSchema() Schema {
+	fields := []FieldSpec{
+		{Key: "module_paths", Type: TypePath, Default: "~/.t-rex/modules"},
+		{Key: "use_colors", Type: TypeBool, Default: "true"},
+		{Key: "theme", Type: TypeEnum, Options: []string{"default", "dark", "light", "minimal"}, Default: "default"},
+		{Key: "history_enabled", Type: TypeBool, Default: "true"},
+		{Key: "history_size", Type: TypeInt, Default: "1000"},
+		{Key: "prompt_symbol", Type: TypeString, Default: "❯"},
+		{Key: "prompt_template", Type: TypeString, Default: "❯"},
+		{Key: "prompt_color", Type: TypeEnum, Options: []string{"red", "green", "yellow", "blue", "magenta", "cyan", "white"}, Default: "cyan"},
+		{Key: "python_executable", Type: TypePath, Default: "python3"},
+		{Key: "output_template", Type: TypeString, Default: ""},
+	}
+
+	s := make(Schema, len(fields))
+	for _, f := range fields {
+		s[f.Key] = f
+	}
+	return s
+}
+
+// Validate checks val against spec's type, returning a helpful error
+// (listing valid options for TypeEnum) if it doesn't satisfy it.
+func (spec FieldSpec) Validate(val string) error {
+	switch spec.Type {
+	case TypeInt:
+		if _, err := strconv.Atoi(val); err != nil {
+			return fmt.Errorf("%q is not a valid integer", val)
+		}
+	case TypeBool:
+		if val != "true" && val != "false" {
+			return fmt.Errorf("%q is not a valid boolean (expected true or false)", val)
+		}
+	case TypeEnum:
+		for _, opt := range spec.Options {
+			if val == opt {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a valid option for %s (expected one of: %s)", val, spec.Key, strings.Join(spec.Options, ", "))
+	case TypeString, TypePath:
+		// any non-empty string is acceptable
+	}
+	return nil
+}
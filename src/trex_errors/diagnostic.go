@@ -0,0 +1,329 @@
+package trex_errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Severity is a Diagnostic's level.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// Span is a source range, both ends 1-based and inclusive-exclusive on
+// columns (ColEnd is the column just past the last highlighted rune).
+type Span struct {
+	File      string `json:"file,omitempty"`
+	LineStart int    `json:"line_start"`
+	ColStart  int    `json:"col_start"`
+	LineEnd   int    `json:"line_end"`
+	ColEnd    int    `json:"col_end"`
+}
+
+// Label attaches a message to a span. The primary label explains what
+// went wrong there; secondary labels add supporting context.
+type Label struct {
+	Span    Span   `json:"span"`
+	Message string `json:"message"`
+	Primary bool   `json:"primary"`
+}
+
+// Diagnostic is one structured error/warning/note. A Renderer turns it
+// into either an annotated-source text report (rustc-style) or JSON for
+// editor integrations.
+type Diagnostic struct {
+	Severity  Severity          `json:"severity"`
+	Code      string            `json:"code,omitempty"`
+	Message   string            `json:"message"`
+	Primary   Span              `json:"primary_span"`
+	Secondary []Span            `json:"secondary_spans,omitempty"`
+	Labels    []Label           `json:"labels,omitempty"`
+	Notes     []string          `json:"notes,omitempty"`
+	Help      string            `json:"help,omitempty"`
+	Source    map[string]string `json:"-"` // inline source for spans not backed by a real file (e.g. the REPL line)
+}
+
+// NewDiagnostic creates a Diagnostic with no span set; chain With* to fill it in.
+func NewDiagnostic(sev Severity, message string) *Diagnostic {
+	return &Diagnostic{Severity: sev, Message: message}
+}
+
+func (d *Diagnostic) WithCode(code string) *Diagnostic {
+	d.Code = code
+	return d
+}
+
+// WithPrimarySpan sets the diagnostic's main span. A lineStart of 0 means
+// "no source location" — the renderer then prints the message alone.
+func (d *Diagnostic) WithPrimarySpan(file string, lineStart, colStart, lineEnd, colEnd int) *Diagnostic {
+	d.Primary = Span{File: file, LineStart: lineStart, ColStart: colStart, LineEnd: lineEnd, ColEnd: colEnd}
+	return d
+}
+
+func (d *Diagnostic) WithSecondarySpan(sp Span) *Diagnostic {
+	d.Secondary = append(d.Secondary, sp)
+	return d
+}
+
+func (d *Diagnostic) WithLabel(l Label) *Diagnostic {
+	d.Labels = append(d.Labels, l)
+	return d
+}
+
+func (d *Diagnostic) WithNote(note string) *Diagnostic {
+	d.Notes = append(d.Notes, note)
+	return d
+}
+
+func (d *Diagnostic) WithHelp(help string) *Diagnostic {
+	d.Help = help
+	return d
+}
+
+// WithSource registers inline source text for a pseudo-file name (e.g.
+// "<interactive>"), used when the span doesn't reference a real file.
+func (d *Diagnostic) WithSource(name, text string) *Diagnostic {
+	if d.Source == nil {
+		d.Source = make(map[string]string)
+	}
+	d.Source[name] = text
+	return d
+}
+
+// Renderer prints Diagnostics. Unlike a single ad-hoc format function, it
+// carries printer state — tab width, color, JSON mode — the way a real
+// source-code printer does, so gutter width and column math stay
+// consistent across every label and line printed for one diagnostic.
+type Renderer struct {
+	TabWidth int
+	Color    bool
+	JSON     bool
+}
+
+// NewRenderer returns a Renderer with sensible text-mode defaults.
+func NewRenderer() *Renderer {
+	return &Renderer{TabWidth: 4, Color: true}
+}
+
+// Render writes d to w, as JSON if r.JSON, otherwise as an annotated
+// source report.
+func (r *Renderer) Render(w io.Writer, d *Diagnostic) {
+	if r.JSON {
+		data, err := json.Marshal(d)
+		if err != nil {
+			fmt.Fprintf(w, `{"severity":"error","message":%q}`+"\n", err.Error())
+			return
+		}
+		fmt.Fprintln(w, string(data))
+		return
+	}
+	fmt.Fprint(w, r.renderText(d))
+}
+
+func (r *Renderer) color(code string) string {
+	if !r.Color {
+		return ""
+	}
+	return code
+}
+
+func (r *Renderer) renderText(d *Diagnostic) string {
+	var b strings.Builder
+
+	sevColor := r.color(ansiRed)
+	switch d.Severity {
+	case SeverityWarning:
+		sevColor = r.color(ansiYellow)
+	case SeverityNote:
+		sevColor = r.color(ansiCyan)
+	}
+
+	label := strings.ToUpper(string(d.Severity))
+	if d.Code != "" {
+		label = fmt.Sprintf("%s[%s]", label, d.Code)
+	}
+	b.WriteString(fmt.Sprintf("%s%s× %s%s\n\n", r.color(ansiBold), sevColor, label, r.color(ansiReset)))
+	b.WriteString(fmt.Sprintf("  %s%s%s\n", r.color(ansiBold), d.Message, r.color(ansiReset)))
+
+	gutterWidth := r.gutterWidth(d)
+
+	if d.Primary.File != "" {
+		loc := d.Primary.File
+		if d.Primary.LineStart > 0 {
+			loc = fmt.Sprintf("%s:%d:%d", loc, d.Primary.LineStart, d.Primary.ColStart)
+		}
+		b.WriteString(fmt.Sprintf("\n %s╭─[%s]%s\n", r.color(ansiCyan), loc, r.color(ansiReset)))
+		r.renderSpan(&b, d, d.Primary, "", true, gutterWidth)
+	}
+
+	for _, l := range d.Labels {
+		r.renderSpan(&b, d, l.Span, l.Message, l.Primary, gutterWidth)
+	}
+	for _, sp := range d.Secondary {
+		r.renderSpan(&b, d, sp, "", false, gutterWidth)
+	}
+
+	if len(d.Notes) > 0 || d.Help != "" {
+		b.WriteString(fmt.Sprintf(" %s│%s\n", r.color(ansiCyan), r.color(ansiReset)))
+		for _, n := range d.Notes {
+			b.WriteString(fmt.Sprintf(" %s│%s %snote:%s %s\n", r.color(ansiCyan), r.color(ansiReset), r.color(ansiBold), r.color(ansiReset), n))
+		}
+		if d.Help != "" {
+			b.WriteString(fmt.Sprintf(" %s│%s %shelp:%s %s\n", r.color(ansiCyan), r.color(ansiReset), r.color(ansiBold), r.color(ansiReset), d.Help))
+		}
+	}
+
+	b.WriteString(fmt.Sprintf(" %s╰────%s\n\n", r.color(ansiCyan), r.color(ansiReset)))
+	return b.String()
+}
+
+// gutterWidth sizes the line-number column from the largest line number
+// referenced by any span, so every "NNN │" prefix in the report lines up.
+func (r *Renderer) gutterWidth(d *Diagnostic) int {
+	max := d.Primary.LineEnd
+	if d.Primary.LineStart > max {
+		max = d.Primary.LineStart
+	}
+	for _, l := range d.Labels {
+		if l.Span.LineEnd > max {
+			max = l.Span.LineEnd
+		}
+	}
+	for _, sp := range d.Secondary {
+		if sp.LineEnd > max {
+			max = sp.LineEnd
+		}
+	}
+	width := len(fmt.Sprintf("%d", max))
+	if width < 1 {
+		width = 1
+	}
+	return width
+}
+
+// sourceLines returns file's content split into lines, preferring d's
+// inline Source override (for spans not backed by a real file) and
+// falling back to reading the file from disk.
+func (r *Renderer) sourceLines(d *Diagnostic, file string) []string {
+	if d.Source != nil {
+		if src, ok := d.Source[file]; ok {
+			return strings.Split(src, "\n")
+		}
+	}
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+func (r *Renderer) renderSpan(b *strings.Builder, d *Diagnostic, sp Span, message string, primary bool, gutterWidth int) {
+	lines := r.sourceLines(d, sp.File)
+
+	if lines == nil || sp.LineStart <= 0 {
+		if message != "" {
+			b.WriteString(fmt.Sprintf(" %s│%s %s\n", r.color(ansiCyan), r.color(ansiReset), message))
+		}
+		return
+	}
+
+	blankGutter := strings.Repeat(" ", gutterWidth) + " │ "
+
+	from := sp.LineStart - 2
+	if from < 1 {
+		from = 1
+	}
+	to := sp.LineStart + 2
+	if to > len(lines) {
+		to = len(lines)
+	}
+
+	b.WriteString(fmt.Sprintf(" %s│%s\n", r.color(ansiCyan), r.color(ansiReset)))
+	for ln := from; ln <= to; ln++ {
+		if ln-1 >= len(lines) {
+			break
+		}
+		text := expandTabs(lines[ln-1], r.TabWidth)
+		b.WriteString(fmt.Sprintf("%s%*d │ %s%s\n", r.color(ansiCyan), gutterWidth, ln, r.color(ansiReset), text))
+
+		if ln == sp.LineStart && sp.ColStart > 0 {
+			colStart := displayColumn(lines[ln-1], sp.ColStart-1, r.TabWidth)
+			colEnd := sp.ColEnd
+			if colEnd <= sp.ColStart {
+				colEnd = sp.ColStart + 1
+			}
+			width := displayColumn(lines[ln-1], colEnd-1, r.TabWidth) - colStart
+			if width < 1 {
+				width = 1
+			}
+
+			underlineColor := r.color(ansiRed)
+			if !primary {
+				underlineColor = r.color(ansiYellow)
+			}
+
+			b.WriteString(fmt.Sprintf("%s%s%s%s%s%s%s", r.color(ansiCyan), blankGutter, r.color(ansiReset),
+				strings.Repeat(" ", colStart), underlineColor+r.color(ansiBold), strings.Repeat("^", width), r.color(ansiReset)))
+			if message != "" {
+				b.WriteString(" " + message)
+			}
+			b.WriteString("\n")
+		}
+	}
+}
+
+// expandTabs replaces tabs with spaces up to the next width-wide stop,
+// so column math downstream doesn't have to special-case them.
+func expandTabs(s string, width int) string {
+	if width <= 0 {
+		width = 4
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			spaces := width - (col % width)
+			b.WriteString(strings.Repeat(" ", spaces))
+			col += spaces
+		} else {
+			b.WriteRune(r)
+			col += runewidth.RuneWidth(r)
+		}
+	}
+	return b.String()
+}
+
+// displayColumn converts a 0-based rune offset into line into a 0-based
+// display-column offset, expanding tabs and accounting for
+// double-width runes along the way.
+func displayColumn(line string, runeOffset int, tabWidth int) int {
+	if tabWidth <= 0 {
+		tabWidth = 4
+	}
+	col := 0
+	i := 0
+	for _, r := range line {
+		if i >= runeOffset {
+			break
+		}
+		if r == '\t' {
+			col += tabWidth - (col % tabWidth)
+		} else {
+			col += runewidth.RuneWidth(r)
+		}
+		i++
+	}
+	return col
+}
@@ -8,11 +8,12 @@ import (
 
 // ANSI color codes (avoid importing trex_utils to prevent cycles)
 const (
-	ansiReset = "\033[0m"
-	ansiRed   = "\033[31m"
-	ansiCyan  = "\033[36m"
-	ansiBold  = "\033[1m"
-	ansiDim   = "\033[2m"
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+	ansiBold   = "\033[1m"
+	ansiDim    = "\033[2m"
 )
 
 // ErrorType represents different error categories
@@ -0,0 +1,220 @@
+package trex_errors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ByteSpan is a byte range into one file's source text, end-exclusive.
+type ByteSpan struct {
+	Start int
+	End   int
+}
+
+// ReportLabel annotates a ByteSpan with a message and color. Primary labels
+// get a solid underline ("─"); secondary labels (extra context, not the
+// root cause) get a dotted one ("·").
+type ReportLabel struct {
+	File    string
+	Span    ByteSpan
+	Message string
+	Color   string
+	Primary bool
+}
+
+// SourceMap holds the full text of every file referenced by a Report's
+// labels, keyed by filename (or a synthetic name like "<stdin>").
+type SourceMap map[string]string
+
+// Report is a Rust/ariadne-style multi-span diagnostic: one or more labels,
+// each pointing at a byte range in some file's source, rendered together
+// with connecting gutters and optional notes/help text.
+type Report struct {
+	Source SourceMap
+	Labels []ReportLabel
+	Notes  []string
+	Help   string
+}
+
+// NewReport creates an empty report against the given source map.
+func NewReport(source SourceMap) *Report {
+	if source == nil {
+		source = SourceMap{}
+	}
+	return &Report{Source: source}
+}
+
+// AddLabel attaches a label spanning [start, end) in file. The label is
+// primary only if no label added so far is primary, so the first AddLabel
+// call on an empty report is primary and later ones are secondary. A
+// primary label added afterwards via AddLabelFull does not demote it.
+func (r *Report) AddLabel(file string, start, end int, msg, color string) *Report {
+	primary := true
+	for _, l := range r.Labels {
+		if l.Primary {
+			primary = false
+			break
+		}
+	}
+	r.Labels = append(r.Labels, ReportLabel{File: file, Span: ByteSpan{Start: start, End: end}, Message: msg, Color: color, Primary: primary})
+	return r
+}
+
+// AddLabelFull attaches a fully-specified label, letting callers mark
+// secondary context spans explicitly.
+func (r *Report) AddLabelFull(l ReportLabel) *Report {
+	r.Labels = append(r.Labels, l)
+	return r
+}
+
+// WithHelp sets the report's trailing hint line.
+func (r *Report) WithHelp(hint string) *Report {
+	r.Help = hint
+	return r
+}
+
+// WithNote appends a note line, rendered after the source but before help.
+func (r *Report) WithNote(note string) *Report {
+	r.Notes = append(r.Notes, note)
+	return r
+}
+
+// lineCol converts a byte offset into 1-based (line, col) against src.
+func lineCol(src string, offset int) (line, col int) {
+	line, col = 1, 1
+	for i := 0; i < offset && i < len(src); i++ {
+		if src[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return
+}
+
+// lineBounds returns the byte offsets of the start and end of the line
+// that contains offset (the end offset excludes the trailing newline).
+func lineBounds(src string, offset int) (start, end int) {
+	start = strings.LastIndexByte(src[:min(offset, len(src))], '\n') + 1
+	end = len(src)
+	if idx := strings.IndexByte(src[min(offset, len(src)):], '\n'); idx >= 0 {
+		end = offset + idx
+	}
+	return
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Format renders the report Rust/ariadne-style: a "╭─[file:line:col]"
+// header per file (keyed off that file's primary label, or its first
+// label if none is marked primary), the source line each label starts on
+// with a left line-number gutter, and an underline (primary "─", or "·"
+// for secondary) below each labeled line pointing at the label's message.
+// A label spanning several lines is shown on its first line only; the
+// lines after it are not printed.
+func (r *Report) Format() string {
+	if len(r.Labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	byFile := map[string][]ReportLabel{}
+	var fileOrder []string
+	for _, l := range r.Labels {
+		if _, ok := byFile[l.File]; !ok {
+			fileOrder = append(fileOrder, l.File)
+		}
+		byFile[l.File] = append(byFile[l.File], l)
+	}
+
+	for _, file := range fileOrder {
+		labels := byFile[file]
+		src := r.Source[file]
+
+		header := labels[0]
+		for _, l := range labels {
+			if l.Primary {
+				header = l
+				break
+			}
+		}
+		hLine, hCol := lineCol(src, header.Span.Start)
+		b.WriteString(fmt.Sprintf(" %s╭─[%s:%d:%d]%s\n", ansiCyan, file, hLine, hCol, ansiReset))
+
+		// Group labels by the line they start on so multi-label lines
+		// stack an underline row per label.
+		byLine := map[int][]ReportLabel{}
+		var lineOrder []int
+		for _, l := range labels {
+			ln, _ := lineCol(src, l.Span.Start)
+			if _, ok := byLine[ln]; !ok {
+				lineOrder = append(lineOrder, ln)
+			}
+			byLine[ln] = append(byLine[ln], l)
+		}
+		sort.Ints(lineOrder)
+
+		gutterWidth := len(fmt.Sprintf("%d", lineOrder[len(lineOrder)-1]))
+
+		for _, ln := range lineOrder {
+			lineLabels := byLine[ln]
+			start, end := lineBounds(src, lineLabels[0].Span.Start)
+			text := src[start:end]
+
+			b.WriteString(fmt.Sprintf(" %s%*d │%s %s\n", ansiCyan, gutterWidth, ln, ansiReset, text))
+
+			for _, l := range lineLabels {
+				_, col := lineCol(src, l.Span.Start)
+				width := l.Span.End - l.Span.Start
+				if width < 1 {
+					width = 1
+				}
+				mark := "·"
+				if l.Primary {
+					mark = "─"
+				}
+				color := l.Color
+				if color == "" {
+					color = ansiRed
+				}
+				underline := strings.Repeat(mark, width)
+				b.WriteString(fmt.Sprintf(" %s%*s │%s %s%s %s\n",
+					ansiCyan, gutterWidth, "", ansiReset,
+					strings.Repeat(" ", col-1), color+underline+ansiReset, l.Message))
+			}
+		}
+		b.WriteString(fmt.Sprintf(" %s╰────%s\n", ansiCyan, ansiReset))
+	}
+
+	for _, note := range r.Notes {
+		b.WriteString(fmt.Sprintf(" %snote:%s %s\n", ansiBold, ansiReset, note))
+	}
+	if r.Help != "" {
+		b.WriteString(fmt.Sprintf(" %shelp:%s %s\n", ansiCyan+ansiBold, ansiReset, r.Help))
+	}
+
+	return b.String()
+}
+
+// ToReport builds a single-label Report from this TRexError's Context,
+// Message and Hint, so new call sites can opt into the multi-span renderer
+// without every error constructor changing. Context is the report's whole
+// source for e.File, so its header shows line 1 rather than e.Line.
+func (e *TRexError) ToReport() *Report {
+	r := NewReport(SourceMap{e.File: e.Context})
+	if e.Context != "" {
+		r.AddLabel(e.File, 0, len(e.Context), e.Message, ansiRed)
+	}
+	if e.Hint != "" {
+		r.WithHelp(e.Hint)
+	}
+	return r
+}
@@ -0,0 +1,308 @@
+package trex_modules
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"trex_utils"
+)
+
+// Adapter is the contract every module runtime must implement. It owns both
+// discovery (which files belong to it) and execution (how to run them).
+type Adapter interface {
+	// Name identifies the adapter, e.g. "python", "node", "wasm".
+	Name() string
+
+	// Extensions lists the file extensions this adapter claims, without the
+	// leading dot (e.g. "py", "mjs").
+	Extensions() []string
+
+	// Detect reports whether path is handled by this adapter, either by
+	// extension or by inspecting a shebang line.
+	Detect(path string) bool
+
+	// Invoke runs the module at path with args and the given stdin payload
+	// (already JSON-encoded, or empty), returning raw stdout.
+	Invoke(path string, args []string, stdinJSON string) (string, error)
+
+	// ValidateOutput turns raw module stdout into the shell's result map.
+	// Adapters whose modules emit plain text instead of JSON can override
+	// this to wrap the text rather than fail to parse it.
+	ValidateOutput(output string) (map[string]interface{}, error)
+}
+
+// shebangPattern is checked against the first line of a file when an
+// adapter's extension list doesn't match, so extension-less scripts
+// (`#!/usr/bin/env python3`) are still detected correctly.
+type shebangPattern struct {
+	substr string
+}
+
+func firstLine(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if scanner.Scan() {
+		return scanner.Text()
+	}
+	return ""
+}
+
+func hasExtension(path string, exts []string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func hasShebang(path string, patterns []shebangPattern) bool {
+	line := firstLine(path)
+	if !strings.HasPrefix(line, "#!") {
+		return false
+	}
+	for _, p := range patterns {
+		if strings.Contains(line, p.substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultInvokeTimeout bounds a one-shot runInterpreter call. It's the
+// one-shot counterpart to Worker.Call's defaultCallTimeout: InvokeWarm falls
+// back to Adapter.Invoke (and therefore runInterpreter) for any module that
+// doesn't speak the worker protocol, so that path needs the same "a hung
+// module can't wedge the shell forever" guarantee the warm path already has.
+const defaultInvokeTimeout = 5 * time.Minute
+
+// runInterpreter execs interpreter+extraArgs+path+args, feeding stdinJSON
+// on stdin and returning stdout. This is the common shape shared by the
+// Python/Node/Ruby/shell adapters. The process runs under defaultInvokeTimeout,
+// via trex_utils.RunBounded, so a hung module is killed (its whole process
+// group, not just the immediate child) rather than blocking the caller
+// indefinitely.
+func runInterpreter(interpreter string, extraArgs []string, path string, args []string, stdinJSON string, env []string) (string, error) {
+	cmdArgs := append(append([]string{}, extraArgs...), path)
+	cmdArgs = append(cmdArgs, args...)
+	cmd := exec.Command(interpreter, cmdArgs...)
+	if env != nil {
+		cmd.Env = env
+	}
+	if stdinJSON != "" {
+		cmd.Stdin = strings.NewReader(stdinJSON)
+	}
+
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+
+	output, err := trex_utils.RunBounded(context.Background(), cmd, trex_utils.ExecOptions{MaxRuntime: defaultInvokeTimeout})
+	if err != nil {
+		if errBuf.Len() > 0 {
+			return "", fmt.Errorf("%s: %w: %s", interpreter, err, strings.TrimSpace(errBuf.String()))
+		}
+		return "", fmt.Errorf("%s: %w", interpreter, err)
+	}
+
+	return output, nil
+}
+
+func defaultValidate(output string) (map[string]interface{}, error) {
+	return ValidateModuleOutput(output)
+}
+
+// PythonAdapter runs .py modules under python3 (overridable via config).
+type PythonAdapter struct {
+	Interpreter string
+	Env         []string
+}
+
+func (a *PythonAdapter) Name() string         { return "python" }
+func (a *PythonAdapter) Extensions() []string { return []string{"py"} }
+func (a *PythonAdapter) Detect(path string) bool {
+	return hasExtension(path, a.Extensions()) || hasShebang(path, []shebangPattern{{"python"}})
+}
+func (a *PythonAdapter) Invoke(path string, args []string, stdinJSON string) (string, error) {
+	interp := a.Interpreter
+	if interp == "" {
+		interp = "python3"
+	}
+	return runInterpreter(interp, nil, path, args, stdinJSON, a.Env)
+}
+func (a *PythonAdapter) ValidateOutput(output string) (map[string]interface{}, error) {
+	return defaultValidate(output)
+}
+
+// NodeAdapter runs .js/.mjs modules under node.
+type NodeAdapter struct {
+	Interpreter string
+	Env         []string
+}
+
+func (a *NodeAdapter) Name() string         { return "node" }
+func (a *NodeAdapter) Extensions() []string { return []string{"js", "mjs"} }
+func (a *NodeAdapter) Detect(path string) bool {
+	return hasExtension(path, a.Extensions()) || hasShebang(path, []shebangPattern{{"node"}})
+}
+func (a *NodeAdapter) Invoke(path string, args []string, stdinJSON string) (string, error) {
+	interp := a.Interpreter
+	if interp == "" {
+		interp = "node"
+	}
+	return runInterpreter(interp, nil, path, args, stdinJSON, a.Env)
+}
+func (a *NodeAdapter) ValidateOutput(output string) (map[string]interface{}, error) {
+	return defaultValidate(output)
+}
+
+// RubyAdapter runs .rb modules under ruby.
+type RubyAdapter struct {
+	Interpreter string
+	Env         []string
+}
+
+func (a *RubyAdapter) Name() string         { return "ruby" }
+func (a *RubyAdapter) Extensions() []string { return []string{"rb"} }
+func (a *RubyAdapter) Detect(path string) bool {
+	return hasExtension(path, a.Extensions()) || hasShebang(path, []shebangPattern{{"ruby"}})
+}
+func (a *RubyAdapter) Invoke(path string, args []string, stdinJSON string) (string, error) {
+	interp := a.Interpreter
+	if interp == "" {
+		interp = "ruby"
+	}
+	return runInterpreter(interp, nil, path, args, stdinJSON, a.Env)
+}
+func (a *RubyAdapter) ValidateOutput(output string) (map[string]interface{}, error) {
+	return defaultValidate(output)
+}
+
+// ShellAdapter runs .sh modules under sh (POSIX shell).
+type ShellAdapter struct {
+	Interpreter string
+	Env         []string
+}
+
+func (a *ShellAdapter) Name() string         { return "shell" }
+func (a *ShellAdapter) Extensions() []string { return []string{"sh"} }
+func (a *ShellAdapter) Detect(path string) bool {
+	return hasExtension(path, a.Extensions()) || hasShebang(path, []shebangPattern{{"sh"}})
+}
+func (a *ShellAdapter) Invoke(path string, args []string, stdinJSON string) (string, error) {
+	interp := a.Interpreter
+	if interp == "" {
+		interp = "sh"
+	}
+	return runInterpreter(interp, nil, path, args, stdinJSON, a.Env)
+}
+func (a *ShellAdapter) ValidateOutput(output string) (map[string]interface{}, error) {
+	return defaultValidate(output)
+}
+
+// WasmAdapter loads .wasm modules with wazero and invokes their exported
+// trex_main function. Arguments and the stdin payload are passed through
+// WASI stdin/argv so modules only need to depend on a libc-style runtime.
+type WasmAdapter struct {
+	Env []string
+}
+
+func (a *WasmAdapter) Name() string         { return "wasm" }
+func (a *WasmAdapter) Extensions() []string { return []string{"wasm"} }
+func (a *WasmAdapter) Detect(path string) bool {
+	return hasExtension(path, a.Extensions())
+}
+
+func (a *WasmAdapter) Invoke(path string, args []string, stdinJSON string) (string, error) {
+	ctx := context.Background()
+
+	wasmBytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("wasm: reading %s: %w", path, err)
+	}
+
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+
+	var stdout, stderr bytes.Buffer
+	moduleConfig := wazero.NewModuleConfig().
+		WithStdin(strings.NewReader(stdinJSON)).
+		WithStdout(&stdout).
+		WithStderr(&stderr).
+		WithArgs(append([]string{filepath.Base(path)}, args...)...)
+
+	compiled, err := runtime.CompileModule(ctx, wasmBytes)
+	if err != nil {
+		return "", fmt.Errorf("wasm: compiling %s: %w", path, err)
+	}
+
+	mod, err := runtime.InstantiateModule(ctx, compiled, moduleConfig)
+	if err != nil {
+		return "", fmt.Errorf("wasm: instantiating %s: %w", path, err)
+	}
+	defer mod.Close(ctx)
+
+	fn := mod.ExportedFunction("trex_main")
+	if fn == nil {
+		return "", fmt.Errorf("wasm: %s does not export trex_main", path)
+	}
+	if _, err := fn.Call(ctx); err != nil {
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("wasm: %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("wasm: %s: %w", path, err)
+	}
+
+	return stdout.String(), nil
+}
+
+func (a *WasmAdapter) ValidateOutput(output string) (map[string]interface{}, error) {
+	return defaultValidate(output)
+}
+
+// defaultAdapters returns the built-in adapter set in detection priority
+// order. modules.toml only overrides these adapters' interpreter and env;
+// adapters added with Loader.RegisterAdapter are placed ahead of them and
+// win a tie on extension.
+func defaultAdapters() []Adapter {
+	return []Adapter{
+		&PythonAdapter{},
+		&NodeAdapter{},
+		&RubyAdapter{},
+		&ShellAdapter{},
+		&WasmAdapter{},
+	}
+}
+
+// jsonPreview renders a json.SyntaxError-style offset for diagnostics; kept
+// here (rather than trex_errors) since only adapter output parsing needs it.
+func jsonPreview(output string, offset int64) string {
+	if offset < 0 || int(offset) > len(output) {
+		return output
+	}
+	start := int(offset) - 20
+	if start < 0 {
+		start = 0
+	}
+	end := int(offset) + 20
+	if end > len(output) {
+		end = len(output)
+	}
+	return output[start:end]
+}
@@ -1,63 +1,333 @@
 package trex_modules
 
 import (
+	"bufio"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
-// Loader manages loading Python modules
+// Loader manages loading modules across every registered runtime adapter.
 type Loader struct {
-	paths []string
+	paths    []string
+	adapters []Adapter
+	workers  *WorkerPool
+
+	// nonWarm remembers module paths whose worker handshake (Worker.Init)
+	// has already failed once, so InvokeWarm can skip straight to
+	// adapter.Invoke on every later call instead of re-probing (and paying
+	// defaultInitTimeout) each time. Keyed by modulePath, value unused.
+	nonWarm sync.Map
 }
 
-// NewLoader creates a new module loader
+const (
+	defaultMaxWorkers  = 16
+	defaultIdleTimeout = 5 * time.Minute
+)
+
+// NewLoader creates a new module loader rooted at the given colon-separated
+// search paths. Built-in adapters (python, node, ruby, shell, wasm) are
+// registered, then any interpreter/env overrides declared in
+// ~/.t-rex/modules.toml are applied to them. A worker pool is started so
+// repeated invocations of the same module can reuse a warm interpreter
+// instead of paying fork/exec + interpreter-startup cost every call.
 func NewLoader(paths string) *Loader {
 	var pathList []string
 	if paths != "" {
 		pathList = strings.Split(paths, ":")
 	}
-	return &Loader{paths: pathList}
+
+	l := &Loader{
+		paths:    pathList,
+		adapters: defaultAdapters(),
+		workers:  NewWorkerPool(defaultMaxWorkers, defaultIdleTimeout),
+	}
+	l.loadAdapterConfig()
+	return l
+}
+
+// RegisterAdapter adds a custom adapter, taking priority over adapters
+// registered before it when extensions collide.
+func (l *Loader) RegisterAdapter(a Adapter) {
+	l.adapters = append([]Adapter{a}, l.adapters...)
+}
+
+// AddPath appends a directory to the loader's module search path, e.g. a
+// Registry source's resolved checkout directory.
+func (l *Loader) AddPath(path string) {
+	l.paths = append(l.paths, path)
 }
 
-// FindModule searches for a module in configured paths
-func (l *Loader) FindModule(moduleName string) (string, error) {
-	// Check in configured paths
-	for _, path := range l.paths {
-		modulePath := filepath.Join(path, moduleName+".py")
-		if _, err := os.Stat(modulePath); err == nil {
-			return modulePath, nil
+// FindModule walks the configured search paths once, returning the first
+// file any registered adapter claims along with that adapter.
+func (l *Loader) FindModule(moduleName string) (string, Adapter, error) {
+	searchDirs := append(append([]string{}, l.paths...), ".")
+
+	for _, dir := range searchDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			base := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+			if base != moduleName {
+				continue
+			}
+			candidate := filepath.Join(dir, entry.Name())
+			for _, a := range l.adapters {
+				if a.Detect(candidate) {
+					return candidate, a, nil
+				}
+			}
 		}
 	}
 
-	// Check current directory
-	if _, err := os.Stat(moduleName + ".py"); err == nil {
-		return moduleName + ".py", nil
-	}
+	return "", nil, os.ErrNotExist
+}
 
-	return "", os.ErrNotExist
+// InvalidOutputError carries enough detail about a module's malformed
+// stdout (the raw text and the byte offset json reported, when available)
+// for a caller to render a multi-span trex_errors.Report pointing right at
+// the offending character instead of just printing "invalid JSON".
+type InvalidOutputError struct {
+	Raw    string
+	Offset int64 // -1 if the underlying error didn't carry one
+	Err    error
 }
 
-// ValidateModuleOutput ensures output is valid JSON
+func (e *InvalidOutputError) Error() string {
+	return fmt.Sprintf("module returned invalid JSON: %v", e.Err)
+}
+
+func (e *InvalidOutputError) Unwrap() error { return e.Err }
+
+// ValidateModuleOutput ensures output is valid JSON. Kept as a standalone
+// helper (rather than folded only into Adapter.ValidateOutput) since
+// binary/text-only adapters may call it selectively.
 func ValidateModuleOutput(output string) (map[string]interface{}, error) {
 	var result map[string]interface{}
 	err := json.Unmarshal([]byte(output), &result)
 	if err != nil {
-		return nil, err
+		offset := int64(-1)
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			offset = syntaxErr.Offset
+		}
+		return nil, &InvalidOutputError{Raw: output, Offset: offset, Err: err}
 	}
 	return result, nil
 }
 
-// GetModuleInfo returns information about a module
+// InvokeWarm runs modulePath through the shared worker pool when its
+// adapter supports persistent workers (currently python and node); every
+// other adapter falls back to a fresh invocation, since e.g. wasm modules
+// are cheap to instantiate and shell scripts have no notion of staying
+// resident.
+//
+// Not every python/node file speaks the contrib/trex worker protocol,
+// though — an ordinary "read argv/stdin JSON, print JSON, exit" module never
+// answers the Init handshake. Rather than requiring such modules to declare
+// themselves up front, InvokeWarm probes: the first call attempts the warm
+// path, and if the worker never completes Init, the module path is
+// remembered in l.nonWarm so every subsequent call goes straight to the
+// one-shot adapter.Invoke fallback instead of re-paying the handshake
+// timeout.
+func (l *Loader) InvokeWarm(modulePath string, adapter Adapter, args []string) (map[string]interface{}, error) {
+	interpreter, extraArgs, env, ok := workerSpawnFor(adapter)
+	if !ok || l.isNonWarm(modulePath) {
+		output, err := adapter.Invoke(modulePath, args, "")
+		if err != nil {
+			return nil, err
+		}
+		return adapter.ValidateOutput(output)
+	}
+
+	w, err := l.workers.Get(modulePath, func() (*Worker, error) {
+		return NewWorker(interpreter, extraArgs, modulePath, env)
+	})
+	if err != nil {
+		// Get fails when the spawned process never completes the worker
+		// handshake, almost always because modulePath is a plain module
+		// that doesn't implement the contrib/trex protocol at all rather
+		// than a crash. Treat that the same as !ok above: remember it and
+		// fall back to the one-shot contract every adapter must support.
+		l.markNonWarm(modulePath)
+		output, invokeErr := adapter.Invoke(modulePath, args, "")
+		if invokeErr != nil {
+			return nil, invokeErr
+		}
+		return adapter.ValidateOutput(output)
+	}
+
+	return w.Call(args)
+}
+
+func (l *Loader) isNonWarm(modulePath string) bool {
+	_, ok := l.nonWarm.Load(modulePath)
+	return ok
+}
+
+func (l *Loader) markNonWarm(modulePath string) {
+	l.nonWarm.Store(modulePath, struct{}{})
+}
+
+// workerSpawnFor extracts the interpreter/env an adapter would use to spawn
+// a one-shot invocation, so the worker pool can reuse the exact same
+// command line for its long-lived process.
+func workerSpawnFor(a Adapter) (interpreter string, extraArgs []string, env []string, ok bool) {
+	switch typed := a.(type) {
+	case *PythonAdapter:
+		interp := typed.Interpreter
+		if interp == "" {
+			interp = "python3"
+		}
+		return interp, nil, typed.Env, true
+	case *NodeAdapter:
+		interp := typed.Interpreter
+		if interp == "" {
+			interp = "node"
+		}
+		return interp, nil, typed.Env, true
+	default:
+		return "", nil, nil, false
+	}
+}
+
+// Shutdown tears down every warm worker. Call this on shell exit.
+func (l *Loader) Shutdown() {
+	l.workers.Shutdown()
+}
+
+// GetModuleInfo returns information about a module.
 func (l *Loader) GetModuleInfo(moduleName string) map[string]interface{} {
-	path, err := l.FindModule(moduleName)
+	path, adapter, err := l.FindModule(moduleName)
 	if err != nil {
 		return nil
 	}
 
 	return map[string]interface{}{
-		"name": moduleName,
-		"path": path,
+		"name":    moduleName,
+		"path":    path,
+		"adapter": adapter.Name(),
+	}
+}
+
+// adapterConfig is one [adapters.<name>] section of modules.toml.
+type adapterConfig struct {
+	Interpreter string
+	Env         []string
+}
+
+// loadAdapterConfig reads ~/.t-rex/modules.toml, if present, and applies
+// per-adapter interpreter/env overrides. The format is a small TOML subset:
+//
+//	[adapters.python]
+//	interpreter = "python3.12"
+//	env = ["PYTHONDONTWRITEBYTECODE=1"]
+func (l *Loader) loadAdapterConfig() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	configPath := filepath.Join(home, ".t-rex", "modules.toml")
+	f, err := os.Open(configPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	configs := map[string]*adapterConfig{}
+	section := ""
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[adapters.") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSuffix(strings.TrimPrefix(line, "[adapters."), "]")
+			configs[section] = &adapterConfig{}
+			continue
+		}
+		if section == "" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		val := strings.TrimSpace(kv[1])
+		switch key {
+		case "interpreter":
+			configs[section].Interpreter = strings.Trim(val, `"`)
+		case "env":
+			configs[section].Env = parseTomlStringArray(val)
+		}
+	}
+
+	for name, cfg := range configs {
+		l.applyAdapterConfig(name, cfg)
+	}
+}
+
+func (l *Loader) applyAdapterConfig(name string, cfg *adapterConfig) {
+	env := os.Environ()
+	env = append(env, cfg.Env...)
+
+	for _, a := range l.adapters {
+		switch typed := a.(type) {
+		case *PythonAdapter:
+			if name == "python" {
+				typed.Interpreter = cfg.Interpreter
+				typed.Env = env
+			}
+		case *NodeAdapter:
+			if name == "node" {
+				typed.Interpreter = cfg.Interpreter
+				typed.Env = env
+			}
+		case *RubyAdapter:
+			if name == "ruby" {
+				typed.Interpreter = cfg.Interpreter
+				typed.Env = env
+			}
+		case *ShellAdapter:
+			if name == "shell" {
+				typed.Interpreter = cfg.Interpreter
+				typed.Env = env
+			}
+		case *WasmAdapter:
+			if name == "wasm" {
+				typed.Env = env
+			}
+		}
+	}
+}
+
+// parseTomlStringArray parses a one-line TOML array of strings, e.g.
+// ["FOO=1", "BAR=2"]. It deliberately does not support multi-line arrays
+// or nested structures, matching the rest of modules.toml's scope.
+func parseTomlStringArray(val string) []string {
+	val = strings.TrimSpace(val)
+	if !strings.HasPrefix(val, "[") || !strings.HasSuffix(val, "]") {
+		return nil
+	}
+	inner := val[1 : len(val)-1]
+	var out []string
+	for _, part := range strings.Split(inner, ",") {
+		part = strings.TrimSpace(part)
+		part = strings.Trim(part, `"`)
+		if part != "" {
+			out = append(out, part)
+		}
 	}
+	return out
 }
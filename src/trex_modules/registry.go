@@ -0,0 +1,235 @@
+package trex_modules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SourceKind is how a registry source's modules are obtained.
+type SourceKind string
+
+const (
+	SourceLocal SourceKind = "local"
+	SourceGit   SourceKind = "git"
+	SourceHTTP  SourceKind = "http"
+)
+
+// Source is one entry a user has added with `trex module add`.
+type Source struct {
+	Kind     SourceKind `json:"kind"`
+	Location string     `json:"location"`
+}
+
+// ParseSource classifies raw (a `module_paths`-style entry or an
+// explicit `trex module add <source>` argument) into a local directory,
+// a git remote, or an HTTP archive.
+func ParseSource(raw string) Source {
+	switch {
+	case strings.HasSuffix(raw, ".git"), strings.HasPrefix(raw, "git@"), strings.HasPrefix(raw, "git://"):
+		return Source{Kind: SourceGit, Location: raw}
+	case strings.HasPrefix(raw, "http://"), strings.HasPrefix(raw, "https://"):
+		if strings.HasSuffix(raw, ".tar.gz") || strings.HasSuffix(raw, ".tgz") || strings.HasSuffix(raw, ".zip") {
+			return Source{Kind: SourceHTTP, Location: raw}
+		}
+		return Source{Kind: SourceGit, Location: raw}
+	default:
+		return Source{Kind: SourceLocal, Location: raw}
+	}
+}
+
+// cacheKey derives the directory a remote source is materialized into,
+// under <cacheDir>/sources/<key>, so re-adding the same URL reuses the
+// same checkout.
+func cacheKey(location string) string {
+	h := sha256.Sum256([]byte(location))
+	return hex.EncodeToString(h[:])[:16]
+}
+
+// Manifest describes a single module, read from a sibling
+// "<name>.manifest.json" file next to its entrypoint.
+type Manifest struct {
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	Entrypoint       string `json:"entrypoint"`
+	PythonExecutable string `json:"python_executable,omitempty"`
+}
+
+// Validate reports a human-readable problem with the manifest, or nil.
+func (m *Manifest) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("manifest missing required field: name")
+	}
+	if m.Entrypoint == "" {
+		return fmt.Errorf("manifest missing required field: entrypoint")
+	}
+	if m.PythonExecutable != "" {
+		if _, err := exec.LookPath(m.PythonExecutable); err != nil {
+			return fmt.Errorf("manifest requires python_executable %q, not found on PATH", m.PythonExecutable)
+		}
+	}
+	return nil
+}
+
+// Registry tracks the sources `trex module add/list/remove/update`
+// manage, persisted to <trexDir>/registry.json, and resolves them into
+// the local directories the Loader should search (cloning/pulling git
+// sources and extracting HTTP archives into <cacheDir>/sources/<key>).
+type Registry struct {
+	trexDir  string
+	cacheDir string
+	Sources  []Source `json:"sources"`
+}
+
+// NewRegistry loads <trexDir>/registry.json, if present, seeded with an
+// empty source list otherwise.
+func NewRegistry(trexDir string) *Registry {
+	r := &Registry{trexDir: trexDir, cacheDir: filepath.Join(trexDir, "cache", "sources")}
+	data, err := os.ReadFile(r.path())
+	if err == nil {
+		json.Unmarshal(data, r)
+	}
+	return r
+}
+
+func (r *Registry) path() string {
+	return filepath.Join(r.trexDir, "registry.json")
+}
+
+func (r *Registry) save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path(), data, 0644)
+}
+
+// Add registers a new source (classified via ParseSource) and persists
+// the registry, but doesn't fetch it yet — call Update for that.
+func (r *Registry) Add(raw string) error {
+	src := ParseSource(raw)
+	for _, existing := range r.Sources {
+		if existing.Location == src.Location {
+			return fmt.Errorf("module source %s is already registered", raw)
+		}
+	}
+	r.Sources = append(r.Sources, src)
+	return r.save()
+}
+
+// Remove unregisters a source by its original location string.
+func (r *Registry) Remove(raw string) error {
+	for i, src := range r.Sources {
+		if src.Location == raw {
+			r.Sources = append(r.Sources[:i], r.Sources[i+1:]...)
+			return r.save()
+		}
+	}
+	return fmt.Errorf("module source %s is not registered", raw)
+}
+
+// List returns every registered source.
+func (r *Registry) List() []Source {
+	return r.Sources
+}
+
+// Update clones (or pulls) every git source and fetches every HTTP
+// archive source into the cache, returning one error per source that
+// failed rather than aborting on the first failure.
+func (r *Registry) Update() []error {
+	var errs []error
+	for _, src := range r.Sources {
+		if err := r.updateSource(src); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.Location, err))
+		}
+	}
+	return errs
+}
+
+func (r *Registry) updateSource(src Source) error {
+	switch src.Kind {
+	case SourceLocal:
+		// Nothing to fetch; the directory is used as-is.
+		return nil
+	case SourceGit:
+		dir := filepath.Join(r.cacheDir, cacheKey(src.Location))
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			cmd := exec.Command("git", "-C", dir, "pull", "--ff-only")
+			return cmd.Run()
+		}
+		if err := os.MkdirAll(r.cacheDir, 0755); err != nil {
+			return err
+		}
+		cmd := exec.Command("git", "clone", "--depth", "1", src.Location, dir)
+		return cmd.Run()
+	case SourceHTTP:
+		dir := filepath.Join(r.cacheDir, cacheKey(src.Location))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		return fetchAndExtractArchive(src.Location, dir)
+	default:
+		return fmt.Errorf("unknown source kind %q", src.Kind)
+	}
+}
+
+// ResolvedPaths turns every registered source into a local directory the
+// Loader can search: a local source's own path, or a remote source's
+// cache checkout.
+func (r *Registry) ResolvedPaths() []string {
+	paths := make([]string, 0, len(r.Sources))
+	for _, src := range r.Sources {
+		switch src.Kind {
+		case SourceLocal:
+			paths = append(paths, src.Location)
+		case SourceGit, SourceHTTP:
+			paths = append(paths, filepath.Join(r.cacheDir, cacheKey(src.Location)))
+		}
+	}
+	return paths
+}
+
+// Discover reads and validates every "*.manifest.json" file in each
+// resolved source directory, without invoking anything — modules are still
+// loaded lazily by the Loader on first use. Module files without a
+// manifest are not listed, and the entrypoint a manifest names is not
+// checked for existence.
+func (r *Registry) Discover() ([]Manifest, []error) {
+	var manifests []Manifest
+	var errs []error
+
+	for _, dir := range r.ResolvedPaths() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", dir, err))
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".manifest.json") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+				continue
+			}
+			var m Manifest
+			if err := json.Unmarshal(data, &m); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+				continue
+			}
+			if err := m.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+				continue
+			}
+			manifests = append(manifests, m)
+		}
+	}
+
+	return manifests, errs
+}
@@ -0,0 +1,526 @@
+package trex_modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Design note: this file is the "persistent interpreter over JSON-RPC"
+// subsystem, covering request multiplexing, the Python-side reference
+// runner (contrib/python/trex), and heartbeat/restart-on-crash in one
+// place. Worker/WorkerPool take an arbitrary interpreter command line
+// instead of hardcoding python3, and the resident process is the module
+// file itself rather than a separate worker entrypoint. workerSpawnFor
+// supplies that command line for the python and node adapters only; the
+// other adapters (ruby, shell, wasm) always run one-shot.
+
+// rpcRequest is a JSON-RPC 2.0 request/notification sent to a worker.
+// Notifications (log/progress) omit ID entirely rather than sending null,
+// since workers use presence of "id" to decide whether to reply.
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      *int64      `json:"id,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response or server-initiated notification.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"` // set on notifications (log/progress)
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      *int64          `json:"id,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message) }
+
+// Worker keeps one module interpreter alive across calls, talking
+// newline-delimited JSON-RPC 2.0 over its stdin/stdout. This turns
+// startup-dominated interpreter calls into sub-millisecond hot dispatches.
+type Worker struct {
+	modulePath string
+
+	cmd    *exec.Cmd
+	stdin  *bufio.Writer
+	stdout *bufio.Reader
+
+	mu       sync.Mutex
+	nextID   int64
+	pending  map[int64]chan rpcResponse
+	lastUsed atomic.Int64 // unix nano, read by the pool's idle reaper
+	alive    atomic.Bool  // cleared once readLoop sees EOF/an error, or a heartbeat ping times out
+
+	// writeMu serializes writes to stdin. w.mu only guards pending/nextID;
+	// without a separate lock, a Call and a heartbeat Ping running
+	// concurrently (same warm worker, shared across a parallel
+	// foreach/forloop) could interleave their bytes mid-frame on the wire.
+	writeMu sync.Mutex
+
+	// OnLog and OnProgress receive server-initiated notifications; either
+	// may be left nil if the caller doesn't care.
+	OnLog      func(line string)
+	OnProgress func(payload json.RawMessage)
+
+	done chan struct{}
+}
+
+// NewWorker spawns interpreter with extraArgs+modulePath and starts the
+// JSON-RPC read loop. The caller must call Init before the first Call.
+func NewWorker(interpreter string, extraArgs []string, modulePath string, env []string) (*Worker, error) {
+	args := append(append([]string{}, extraArgs...), modulePath)
+	cmd := exec.Command(interpreter, args...)
+	if env != nil {
+		cmd.Env = env
+	}
+
+	stdinPipe, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	w := &Worker{
+		modulePath: modulePath,
+		cmd:        cmd,
+		stdin:      bufio.NewWriter(stdinPipe),
+		stdout:     bufio.NewReader(stdoutPipe),
+		pending:    make(map[int64]chan rpcResponse),
+		done:       make(chan struct{}),
+	}
+	w.touch()
+	w.alive.Store(true)
+	go w.readLoop()
+	return w, nil
+}
+
+func (w *Worker) touch() {
+	w.lastUsed.Store(time.Now().UnixNano())
+}
+
+// Alive reports whether this worker is still expected to respond: its
+// stdout hasn't hit EOF/an error yet, and (once the pool's heartbeat has
+// run) its last ping didn't time out. A dead worker is never handed back
+// out by WorkerPool.Get; it's evicted and a fresh one spawned instead.
+func (w *Worker) Alive() bool {
+	return w.alive.Load()
+}
+
+// IdleFor reports how long this worker has been unused.
+func (w *Worker) IdleFor() time.Duration {
+	return time.Since(time.Unix(0, w.lastUsed.Load()))
+}
+
+func (w *Worker) readLoop() {
+	defer close(w.done)
+	for {
+		line, err := w.stdout.ReadBytes('\n')
+		if len(line) > 0 {
+			var resp rpcResponse
+			if jerr := json.Unmarshal(line, &resp); jerr == nil {
+				w.dispatch(resp)
+			}
+		}
+		if err != nil {
+			w.alive.Store(false)
+			w.failPending(err)
+			return
+		}
+	}
+}
+
+func (w *Worker) dispatch(resp rpcResponse) {
+	switch resp.Method {
+	case "log":
+		if w.OnLog != nil {
+			var msg string
+			_ = json.Unmarshal(resp.Params, &msg)
+			w.OnLog(msg)
+		}
+		return
+	case "progress":
+		if w.OnProgress != nil {
+			w.OnProgress(resp.Params)
+		}
+		return
+	}
+
+	if resp.ID == nil {
+		return
+	}
+	w.mu.Lock()
+	ch, ok := w.pending[*resp.ID]
+	if ok {
+		delete(w.pending, *resp.ID)
+	}
+	w.mu.Unlock()
+	if ok {
+		ch <- resp
+	}
+}
+
+func (w *Worker) failPending(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, ch := range w.pending {
+		ch <- rpcResponse{Error: &rpcError{Code: -32000, Message: err.Error()}}
+		delete(w.pending, id)
+	}
+}
+
+// writeRequest marshals req and writes it to stdin as one newline-delimited
+// frame, holding writeMu for the duration so a concurrent call/Ping (the
+// warm worker for a module path is shared across parallel foreach/forloop
+// goroutines, and the pool's heartbeat can fire mid-Call) can't interleave
+// its bytes into the middle of this one.
+func (w *Worker) writeRequest(req rpcRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	// Heartbeat pings don't count as use, or the idle reaper would never
+	// see a worker as idle.
+	if req.Method != "ping" {
+		w.touch()
+	}
+	if _, err := w.stdin.Write(data); err != nil {
+		return err
+	}
+	return w.stdin.Flush()
+}
+
+// call sends a request and blocks indefinitely for its matching response.
+// Init and Shutdown don't use it: both pass their own bound to callTimeout.
+func (w *Worker) call(method string, params interface{}) (json.RawMessage, error) {
+	return w.callTimeout(method, params, 0)
+}
+
+// callTimeout sends a request and waits up to timeout for its matching
+// response. timeout <= 0 waits forever.
+func (w *Worker) callTimeout(method string, params interface{}, timeout time.Duration) (json.RawMessage, error) {
+	id := atomic.AddInt64(&w.nextID, 1)
+	req := rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: &id}
+
+	ch := make(chan rpcResponse, 1)
+	w.mu.Lock()
+	w.pending[id] = ch
+	w.mu.Unlock()
+
+	if err := w.writeRequest(req); err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		resp := <-ch
+		w.touch()
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	}
+
+	select {
+	case resp := <-ch:
+		w.touch()
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-w.done:
+		return nil, fmt.Errorf("worker exited before responding to %q", method)
+	case <-time.After(timeout):
+		w.mu.Lock()
+		delete(w.pending, id)
+		w.mu.Unlock()
+		return nil, fmt.Errorf("%q timed out after %s", method, timeout)
+	}
+}
+
+// Ping sends a heartbeat RPC and waits up to timeout for "pong". It reports
+// an error (and leaves alive cleared) if the worker doesn't answer in time
+// or has already exited, so WorkerPool's heartbeat loop knows to evict it.
+func (w *Worker) Ping(timeout time.Duration) error {
+	id := atomic.AddInt64(&w.nextID, 1)
+	req := rpcRequest{JSONRPC: "2.0", Method: "ping", ID: &id}
+
+	ch := make(chan rpcResponse, 1)
+	w.mu.Lock()
+	w.pending[id] = ch
+	w.mu.Unlock()
+
+	if err := w.writeRequest(req); err != nil {
+		w.alive.Store(false)
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			w.alive.Store(false)
+			return resp.Error
+		}
+		return nil
+	case <-w.done:
+		w.alive.Store(false)
+		return fmt.Errorf("worker exited before responding to ping")
+	case <-time.After(timeout):
+		w.mu.Lock()
+		delete(w.pending, id)
+		w.mu.Unlock()
+		w.alive.Store(false)
+		return fmt.Errorf("ping timed out after %s", timeout)
+	}
+}
+
+// defaultInitTimeout bounds how long Init waits for the handshake reply.
+// Without it, a module that doesn't speak the worker protocol at all — the
+// ordinary "read argv/stdin JSON, print JSON, exit" shape most modules
+// use — would never answer "init" and wedge the very first invocation
+// forever, the exact hang defaultCallTimeout/the heartbeat exist to avoid
+// everywhere else. WorkerPool.Get shuts such a worker down and returns the
+// Init error; Loader.InvokeWarm treats that as "this module doesn't
+// implement the worker protocol" and falls back to a one-shot
+// Adapter.Invoke.
+const defaultInitTimeout = 10 * time.Second
+
+// Init performs the worker handshake, giving the module a chance to run
+// one-time setup (import heavy dependencies, warm caches, ...). Bounded by
+// defaultInitTimeout so a non-worker-protocol module fails fast instead of
+// hanging.
+func (w *Worker) Init() error {
+	_, err := w.callTimeout("init", nil, defaultInitTimeout)
+	return err
+}
+
+// defaultCallTimeout bounds how long Call waits for a module's response.
+// Without it, a module stuck in an infinite loop (or one that crashed
+// mid-reply without tripping readLoop's EOF path) would hang the shell
+// command invoking it forever, not just that one command.
+const defaultCallTimeout = 5 * time.Minute
+
+// Call invokes a function inside the warm interpreter and returns its
+// decoded result, giving up after defaultCallTimeout if the module never
+// responds. Use CallContext for a different bound.
+func (w *Worker) Call(args []string) (map[string]interface{}, error) {
+	return w.CallContext(args, defaultCallTimeout)
+}
+
+// CallContext is Call with an explicit timeout (<= 0 waits forever).
+func (w *Worker) CallContext(args []string, timeout time.Duration) (map[string]interface{}, error) {
+	raw, err := w.callTimeout("call", map[string]interface{}{"args": args}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("worker returned invalid JSON: %w", err)
+	}
+	return result, nil
+}
+
+// shutdownTimeout bounds how long Shutdown waits for a "shutdown" reply
+// before giving up and killing the process outright — a module that never
+// speaks the worker protocol at all (e.g. Init already failed on it) won't
+// answer this either, and callTimeout's unbounded wait (timeout <= 0) would
+// hang the reaper/eviction path that calls Shutdown forever.
+const shutdownTimeout = 2 * time.Second
+
+// Shutdown asks the module to exit cleanly, then reaps the process.
+func (w *Worker) Shutdown() error {
+	_, _ = w.callTimeout("shutdown", nil, shutdownTimeout) // writeRequest flushes stdin under writeMu
+	select {
+	case <-w.done:
+	case <-time.After(shutdownTimeout):
+		_ = w.cmd.Process.Kill()
+	}
+	return w.cmd.Wait()
+}
+
+// heartbeatInterval is how often the pool pings its live workers to catch a
+// crashed or wedged interpreter before a caller's next Call hits it.
+const heartbeatInterval = 10 * time.Second
+
+// heartbeatTimeout bounds how long a single ping can take before the worker
+// is considered unresponsive and evicted.
+const heartbeatTimeout = 2 * time.Second
+
+// WorkerPool keeps at most maxWorkers live Worker processes, keyed by
+// module path, and reaps workers idle longer than idleTimeout.
+type WorkerPool struct {
+	mu            sync.Mutex
+	workers       map[string]*Worker
+	maxWorkers    int
+	idleTimeout   time.Duration
+	stopReaper    chan struct{}
+	stopHeartbeat chan struct{}
+}
+
+// NewWorkerPool creates a pool. maxWorkers <= 0 means unbounded.
+func NewWorkerPool(maxWorkers int, idleTimeout time.Duration) *WorkerPool {
+	p := &WorkerPool{
+		workers:       make(map[string]*Worker),
+		maxWorkers:    maxWorkers,
+		idleTimeout:   idleTimeout,
+		stopReaper:    make(chan struct{}),
+		stopHeartbeat: make(chan struct{}),
+	}
+	go p.reapLoop()
+	go p.heartbeatLoop()
+	return p
+}
+
+func (p *WorkerPool) heartbeatLoop() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.checkHeartbeats()
+		case <-p.stopHeartbeat:
+			return
+		}
+	}
+}
+
+// checkHeartbeats pings every live worker and evicts any that fail to
+// respond (or were already dead), so the next Get spawns a fresh one
+// instead of handing back a crashed or wedged interpreter.
+func (p *WorkerPool) checkHeartbeats() {
+	p.mu.Lock()
+	snapshot := make(map[string]*Worker, len(p.workers))
+	for path, w := range p.workers {
+		snapshot[path] = w
+	}
+	p.mu.Unlock()
+
+	for path, w := range snapshot {
+		if !w.Alive() || w.Ping(heartbeatTimeout) != nil {
+			p.evict(path, w)
+		}
+	}
+}
+
+// evict removes w from the pool (only if it's still the current worker for
+// path, in case it was already replaced) and shuts it down.
+func (p *WorkerPool) evict(path string, w *Worker) {
+	p.mu.Lock()
+	if p.workers[path] == w {
+		delete(p.workers, path)
+	}
+	p.mu.Unlock()
+	_ = w.Shutdown()
+}
+
+func (p *WorkerPool) reapLoop() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.reapIdle()
+		case <-p.stopReaper:
+			return
+		}
+	}
+}
+
+func (p *WorkerPool) reapIdle() {
+	p.mu.Lock()
+	var stale []*Worker
+	for path, w := range p.workers {
+		if w.IdleFor() > p.idleTimeout {
+			stale = append(stale, w)
+			delete(p.workers, path)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, w := range stale {
+		_ = w.Shutdown()
+	}
+}
+
+// Get returns the worker for modulePath, spawning one via newWorker if none
+// exists yet (or evicting the oldest worker first if the pool is full).
+func (p *WorkerPool) Get(modulePath string, newWorker func() (*Worker, error)) (*Worker, error) {
+	p.mu.Lock()
+	if w, ok := p.workers[modulePath]; ok {
+		if w.Alive() {
+			p.mu.Unlock()
+			return w, nil
+		}
+		delete(p.workers, modulePath)
+	}
+	if p.maxWorkers > 0 && len(p.workers) >= p.maxWorkers {
+		p.evictOldestLocked()
+	}
+	p.mu.Unlock()
+
+	w, err := newWorker()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Init(); err != nil {
+		// The process never completed the worker handshake — most likely a
+		// plain module that doesn't speak the contrib/trex JSON-RPC
+		// protocol at all, rather than a crash. Don't leak it.
+		_ = w.Shutdown()
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.workers[modulePath] = w
+	p.mu.Unlock()
+	return w, nil
+}
+
+func (p *WorkerPool) evictOldestLocked() {
+	var oldestPath string
+	var oldest time.Duration = -1
+	for path, w := range p.workers {
+		if idle := w.IdleFor(); idle > oldest {
+			oldest = idle
+			oldestPath = path
+		}
+	}
+	if oldestPath != "" {
+		w := p.workers[oldestPath]
+		delete(p.workers, oldestPath)
+		go w.Shutdown() // don't block Get on the shutdown handshake while p.mu is held
+	}
+}
+
+// Shutdown stops the reaper, the heartbeat loop, and every live worker.
+func (p *WorkerPool) Shutdown() {
+	close(p.stopReaper)
+	close(p.stopHeartbeat)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, w := range p.workers {
+		_ = w.Shutdown()
+	}
+	p.workers = make(map[string]*Worker)
+}
@@ -0,0 +1,66 @@
+package trex_plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// CacheKey hashes a node's identity (its command text plus whatever
+// input state it ran against, e.g. the shell variables in scope) into
+// the key its result is stored under in ~/.t-rex/cache/.
+func CacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CacheDir returns (creating it if necessary) ~/.t-rex/cache.
+func CacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".t-rex", "cache")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Get returns the previously cached output for key, if any.
+func Get(key string) (string, bool) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, key))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Has reports whether key already has a cached result, without reading it.
+func Has(key string) bool {
+	dir, err := CacheDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(dir, key))
+	return err == nil
+}
+
+// Set persists output under key, best-effort (a write failure shouldn't
+// break the plan, only cost it a future cache hit).
+func Set(key string, output string) {
+	dir, err := CacheDir()
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(filepath.Join(dir, key), []byte(output), 0644)
+}
@@ -0,0 +1,162 @@
+// Package trex_plan compiles a t-rex script into a dependency DAG instead
+// of a bash-like sequence: each script line becomes a node, edges come
+// from variable reads/writes and %{@} references, and independent nodes
+// can then be grouped into "waves" and run concurrently.
+package trex_plan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Node is one script line turned into a DAG node.
+type Node struct {
+	ID   int
+	Line int // 1-based source line number, for diagnostics
+	Raw  string
+
+	Reads  []string
+	Writes []string
+	Deps   []int // node IDs this node must wait on
+}
+
+// Graph is a script compiled into a dependency DAG.
+type Graph struct {
+	Nodes []*Node
+}
+
+var (
+	assignRe  = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.+)$`)
+	setLetRe  = regexp.MustCompile(`^(?:set|let)\s+\$?([A-Za-z_][A-Za-z0-9_]*)\b`)
+	readVarRe = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)\}?|%\{var:([A-Za-z_][A-Za-z0-9_]*)`)
+	prevOutRe = regexp.MustCompile(`%\{@\}`)
+)
+
+// BuildGraph compiles a script's non-blank, non-comment lines into a
+// Graph. An edge runs from the node that last wrote a variable to every
+// later node that reads it, and from a node to the immediately preceding
+// one whenever it references %{@} (the last pipeline's output).
+func BuildGraph(lines []string) *Graph {
+	g := &Graph{}
+	writers := make(map[string]int)
+	lastNode := -1
+
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		node := &Node{ID: len(g.Nodes), Line: i + 1, Raw: line}
+
+		if m := assignRe.FindStringSubmatch(line); m != nil {
+			node.Writes = append(node.Writes, m[1])
+			node.Reads = append(node.Reads, extractReads(m[2])...)
+		} else if m := setLetRe.FindStringSubmatch(line); m != nil {
+			node.Writes = append(node.Writes, m[1])
+			node.Reads = append(node.Reads, extractReads(line)...)
+		} else {
+			node.Reads = extractReads(line)
+		}
+
+		if prevOutRe.MatchString(line) && lastNode >= 0 {
+			node.Deps = append(node.Deps, lastNode)
+		}
+
+		depSet := make(map[int]bool, len(node.Deps))
+		for _, d := range node.Deps {
+			depSet[d] = true
+		}
+		for _, name := range node.Reads {
+			if w, ok := writers[name]; ok && w != node.ID && !depSet[w] {
+				node.Deps = append(node.Deps, w)
+				depSet[w] = true
+			}
+		}
+
+		for _, name := range node.Writes {
+			writers[name] = node.ID
+		}
+
+		g.Nodes = append(g.Nodes, node)
+		lastNode = node.ID
+	}
+
+	return g
+}
+
+func extractReads(s string) []string {
+	var names []string
+	for _, m := range readVarRe.FindAllStringSubmatch(s, -1) {
+		if m[1] != "" {
+			names = append(names, m[1])
+		} else if m[2] != "" {
+			names = append(names, m[2])
+		}
+	}
+	return names
+}
+
+// Waves groups nodes into the fewest layers such that every node in a
+// layer depends only on nodes in earlier layers — the unit of work that
+// can run concurrently without violating a dependency.
+func (g *Graph) Waves() [][]int {
+	level := make([]int, len(g.Nodes))
+	for _, n := range g.Nodes {
+		max := -1
+		for _, d := range n.Deps {
+			if level[d] > max {
+				max = level[d]
+			}
+		}
+		level[n.ID] = max + 1
+	}
+
+	var waves [][]int
+	for id, lvl := range level {
+		for len(waves) <= lvl {
+			waves = append(waves, nil)
+		}
+		waves[lvl] = append(waves[lvl], id)
+	}
+	return waves
+}
+
+// Dump renders the graph (nodes, deps, and the waves they fall into) for
+// `trex --plan-dump`.
+func (g *Graph) Dump() string {
+	return g.DumpWithCacheCheck(nil)
+}
+
+// DumpWithCacheCheck is like Dump, but additionally reports whether a
+// cached result already exists for each node, via hasCache (nil to skip
+// the check).
+func (g *Graph) DumpWithCacheCheck(hasCache func(n *Node) bool) string {
+	var b strings.Builder
+	waves := g.Waves()
+	fmt.Fprintf(&b, "%d node(s), %d wave(s)\n\n", len(g.Nodes), len(waves))
+
+	for wi, wave := range waves {
+		fmt.Fprintf(&b, "wave %d:\n", wi)
+		for _, id := range wave {
+			n := g.Nodes[id]
+			cached := ""
+			if hasCache != nil && hasCache(n) {
+				cached = "  [cached]"
+			}
+			fmt.Fprintf(&b, "  [%d] line %d: %s%s\n", n.ID, n.Line, n.Raw, cached)
+			if len(n.Deps) > 0 {
+				fmt.Fprintf(&b, "       deps: %v\n", n.Deps)
+			}
+			if len(n.Reads) > 0 {
+				fmt.Fprintf(&b, "       reads: %v\n", n.Reads)
+			}
+			if len(n.Writes) > 0 {
+				fmt.Fprintf(&b, "       writes: %v\n", n.Writes)
+			}
+		}
+	}
+
+	return b.String()
+}
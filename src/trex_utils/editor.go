@@ -4,10 +4,16 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"syscall"
-	"unsafe"
+
+	"github.com/mattn/go-runewidth"
 )
 
+// rawMode is the handle returned by the platform-specific enableRawMode:
+// termios on Unix, console mode flags on Windows. restore() undoes it.
+type rawMode interface {
+	restore() error
+}
+
 // LineEditor handles interactive line editing with history
 type LineEditor struct {
 	history      *History
@@ -27,27 +33,37 @@ func NewLineEditor(hist *History) *LineEditor {
 func (le *LineEditor) ReadLine(prompt string) (string, error) {
 	fmt.Print(prompt)
 
-	// ─── Enter raw mode using syscall/ioctl ───────────────────────
-	oldTermios, err := enableRawMode()
+	// ─── Enter raw mode (platform-specific: termios on Unix, console mode on Windows) ───
+	raw, err := enableRawMode()
 	if err != nil {
 		return "", fmt.Errorf("failed to enter raw mode: %w", err)
 	}
-	defer func() {
-		_ = restoreTermios(oldTermios)
-	}()
+	defer raw.restore()
+
+	resized := make(chan struct{}, 1)
+	stopResize := watchResize(resized)
+	defer stopResize()
 
 	reader := bufio.NewReader(os.Stdin)
 	var line []rune
 	var cursorPos int
 
 	for {
+		select {
+		case <-resized:
+			le.redrawLine(prompt, line, cursorPos)
+		default:
+		}
+
 		// read a rune to correctly handle UTF-8 input
 		r, _, err := reader.ReadRune()
 		if err != nil {
 			return "", err
 		}
 
-		// ESC sequence handling
+		// ESC sequence handling (also covers Windows VT-translated input,
+		// since ENABLE_VIRTUAL_TERMINAL_INPUT makes arrow keys arrive as
+		// the same "\x1b[A".."\x1b[D" sequences as on Unix)
 		if r == 27 {
 			// next should be '['
 			b1, err := reader.ReadByte()
@@ -99,6 +115,20 @@ func (le *LineEditor) ReadLine(prompt string) (string, error) {
 			continue
 		}
 
+		// Ctrl-R: fuzzy incremental reverse history search
+		if r == 18 {
+			accepted, err := le.reverseSearch(reader)
+			if err != nil {
+				return "", err
+			}
+			if accepted != "" {
+				line = []rune(accepted)
+				cursorPos = len(line)
+			}
+			le.redrawLine(prompt, line, cursorPos)
+			continue
+		}
+
 		// Enter
 		if r == '\n' || r == '\r' {
 			fmt.Println()
@@ -159,7 +189,8 @@ func (le *LineEditor) getHistoryLine() string {
 	return historyEntries[len(historyEntries)-1-le.historyIndex]
 }
 
-// redrawLine redraws the current line
+// redrawLine redraws the current line, using display width (not rune
+// count) for cursor math so wide (CJK) and zero-width runes line up.
 func (le *LineEditor) redrawLine(prompt string, line []rune, cursorPos int) {
 	// Move to start of line
 	fmt.Print("\r")
@@ -171,100 +202,11 @@ func (le *LineEditor) redrawLine(prompt string, line []rune, cursorPos int) {
 	fmt.Print(prompt)
 	fmt.Print(string(line))
 
-	// Move cursor to correct position
+	// Move cursor to correct position, measured in display columns
 	if cursorPos < len(line) {
-		moveBack := len(line) - cursorPos
-		fmt.Printf("\033[%dD", moveBack)
-	}
-}
-
-// ────────────────────────────────────────────────
-// Raw mode implementation (Linux/macOS/Unix only)
-// ────────────────────────────────────────────────
-
-type termios struct {
-	Iflag  uint32
-	Oflag  uint32
-	Cflag  uint32
-	Lflag  uint32
-	Line   uint8
-	Cc     [32]uint8
-	Ispeed uint32
-	Ospeed uint32
-}
-
-const (
-	TCGETS = 0x5401
-	TCSETS = 0x5402
-
-	IGNBRK = 0000001
-	BRKINT = 0000002
-	PARMRK = 0000010
-	ISTRIP = 0000020
-	INLCR  = 0000040
-	IGNCR  = 0000100
-	ICRNL  = 0000200
-	IXON   = 0002000
-	OPOST  = 0000001
-	ECHO   = 0000010
-	ECHONL = 0000100
-	ICANON = 0000002
-	ISIG   = 0000001
-	IEXTEN = 0100000
-	CS8    = 0000060
-	CSIZE  = 0000060
-	PARENB = 0000400
-)
-
-func enableRawMode() (*termios, error) {
-	fd := int(os.Stdin.Fd())
-
-	var old termios
-	_, _, e := syscall.Syscall6(
-		syscall.SYS_IOCTL,
-		uintptr(fd),
-		TCGETS,
-		uintptr(unsafe.Pointer(&old)),
-		0, 0, 0,
-	)
-	if e != 0 {
-		return nil, syscall.Errno(e)
-	}
-
-	raw := old
-	raw.Iflag &^= uint32(IGNBRK | BRKINT | PARMRK | ISTRIP | INLCR | IGNCR | ICRNL | IXON)
-	raw.Oflag &^= uint32(OPOST)
-	raw.Lflag &^= uint32(ECHO | ECHONL | ICANON | ISIG | IEXTEN)
-	raw.Cflag &^= uint32(CSIZE | PARENB)
-	raw.Cflag |= uint32(CS8)
-	raw.Cc[syscall.VMIN] = 1
-	raw.Cc[syscall.VTIME] = 0
-
-	_, _, e = syscall.Syscall6(
-		syscall.SYS_IOCTL,
-		uintptr(fd),
-		TCSETS,
-		uintptr(unsafe.Pointer(&raw)),
-		0, 0, 0,
-	)
-	if e != 0 {
-		return nil, syscall.Errno(e)
-	}
-
-	return &old, nil
-}
-
-func restoreTermios(state *termios) error {
-	fd := int(os.Stdin.Fd())
-	_, _, e := syscall.Syscall6(
-		syscall.SYS_IOCTL,
-		uintptr(fd),
-		TCSETS,
-		uintptr(unsafe.Pointer(state)),
-		0, 0, 0,
-	)
-	if e != 0 {
-		return syscall.Errno(e)
+		trailingWidth := runewidth.StringWidth(string(line[cursorPos:]))
+		if trailingWidth > 0 {
+			fmt.Printf("\033[%dD", trailingWidth)
+		}
 	}
-	return nil
 }
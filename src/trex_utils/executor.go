@@ -3,12 +3,14 @@ package trex_utils
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 // PythonExecutor executes Python3 modules
@@ -82,6 +84,231 @@ func (p *PythonExecutor) Execute(moduleName string, args []string) (map[string]i
 	return result, nil
 }
 
+// ExecuteStream runs a Python module expected to emit newline-delimited JSON
+// (JSON Lines) on stdout, decoding records as they arrive instead of
+// buffering the whole output the way Execute does. This is for
+// long-running listing modules whose output can run to MBs/GBs of
+// records — buffering that fully before the first record reaches a
+// pipeline stage defeats progressive rendering.
+//
+// The returned channels are both closed when the module's stdout is
+// exhausted and the process has been reaped: records on the first, and at
+// most one error (a decode failure or the process's exit error) on the
+// second. Callers should range over the record channel and then check the
+// error channel, mirroring the conventional Go fan-in idiom.
+func (p *PythonExecutor) ExecuteStream(moduleName string, args []string) (<-chan map[string]interface{}, <-chan error) {
+	records := make(chan map[string]interface{})
+	errs := make(chan error, 1)
+
+	cmd := exec.Command(p.pythonPath, "-m", moduleName)
+	if p.modulePath != "" {
+		cmd.Env = append(os.Environ(), "PYTHONPATH="+p.modulePath)
+	}
+	cmd.Args = append(cmd.Args, args...)
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		errs <- err
+		close(records)
+		close(errs)
+		return records, errs
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		errs <- err
+		close(records)
+		close(errs)
+		return records, errs
+	}
+
+	if err := cmd.Start(); err != nil {
+		errs <- err
+		close(records)
+		close(errs)
+		return records, errs
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(stderrPipe)
+		for scanner.Scan() {
+			fmt.Fprintln(os.Stderr, scanner.Text())
+		}
+	}()
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+
+		dec := json.NewDecoder(stdoutPipe)
+		for dec.More() {
+			var rec map[string]interface{}
+			if err := dec.Decode(&rec); err != nil {
+				cmd.Wait()
+				errs <- fmt.Errorf("module emitted invalid JSON line: %w", err)
+				return
+			}
+			records <- rec
+		}
+
+		if err := cmd.Wait(); err != nil {
+			errs <- err
+		}
+	}()
+
+	return records, errs
+}
+
+// ExecError is returned by ExecuteContext when a module fails, times out,
+// or is cancelled, carrying the detail Execute's plain error loses: the
+// process's exit code (-1 if it never got one — killed before exiting, or
+// never started), everything it wrote to stderr, and how long it ran.
+type ExecError struct {
+	ExitCode int
+	Stderr   string
+	Duration time.Duration
+	Err      error
+}
+
+func (e *ExecError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%v (exit %d, %s)", e.Err, e.ExitCode, e.Duration)
+	}
+	return fmt.Sprintf("%v (exit %d, %s): %s", e.Err, e.ExitCode, e.Duration, strings.TrimSpace(e.Stderr))
+}
+
+func (e *ExecError) Unwrap() error { return e.Err }
+
+// ExecOptions bounds a PythonExecutor.ExecuteContext call.
+type ExecOptions struct {
+	MaxRuntime     time.Duration // 0 = no bound beyond ctx's own deadline
+	MaxStdoutBytes int64         // 0 = no cap
+}
+
+// DefaultExecOptions is a reasonable bound for interactive use: a module
+// gets a minute and 64MB of stdout before it's killed.
+func DefaultExecOptions() ExecOptions {
+	return ExecOptions{MaxRuntime: time.Minute, MaxStdoutBytes: 64 << 20}
+}
+
+// processGroupGrace is how long ExecuteContext waits after SIGTERM-ing a
+// module's process group before escalating to SIGKILL.
+const processGroupGrace = 3 * time.Second
+
+// RunBounded runs cmd to completion under an enforced time and output-size
+// bound, returning its captured stdout. cmd runs in its own process group
+// (setProcessGroup) so that on cancellation — ctx done, opts.MaxRuntime
+// elapsed, or the stdout cap exceeded — terminateProcessGroup/
+// killProcessGroup can reach any subprocesses it spawned too, not just the
+// immediate child. Failures come back as an *ExecError carrying the exit
+// code, stderr (if the caller left cmd.Stderr unset), and how long the
+// process ran.
+//
+// This is the bounding mechanism behind PythonExecutor.ExecuteContext,
+// exported so other packages that build their own *exec.Cmd for a module
+// invocation (e.g. trex_modules' one-shot adapter fallback) get the same "a
+// hung module can't wedge the caller forever" guarantee instead of each
+// reimplementing process-group kill escalation.
+func RunBounded(ctx context.Context, cmd *exec.Cmd, opts ExecOptions) (string, error) {
+	if opts.MaxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.MaxRuntime)
+		defer cancel()
+	}
+	setProcessGroup(cmd)
+
+	var errBuf bytes.Buffer
+	if cmd.Stderr == nil {
+		cmd.Stderr = &errBuf
+	}
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return "", &ExecError{ExitCode: -1, Err: err}
+	}
+
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			terminateProcessGroup(cmd)
+			select {
+			case <-watchDone:
+			case <-time.After(processGroupGrace):
+				killProcessGroup(cmd)
+			}
+		case <-watchDone:
+		}
+	}()
+
+	fail := func(err error) (string, error) {
+		return "", &ExecError{ExitCode: exitCode(cmd), Stderr: errBuf.String(), Duration: time.Since(start), Err: err}
+	}
+
+	var outBuf bytes.Buffer
+	if opts.MaxStdoutBytes > 0 {
+		limited := &io.LimitedReader{R: stdoutPipe, N: opts.MaxStdoutBytes + 1}
+		if _, err := io.Copy(&outBuf, limited); err != nil {
+			cmd.Wait()
+			return fail(err)
+		}
+		if int64(outBuf.Len()) > opts.MaxStdoutBytes {
+			killProcessGroup(cmd)
+			io.Copy(io.Discard, stdoutPipe)
+			cmd.Wait()
+			return fail(fmt.Errorf("module exceeded the %d byte stdout limit", opts.MaxStdoutBytes))
+		}
+	} else if _, err := io.Copy(&outBuf, stdoutPipe); err != nil {
+		cmd.Wait()
+		return fail(err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("module timed out after %s: %w", time.Since(start), err)
+		}
+		return fail(err)
+	}
+
+	return outBuf.String(), nil
+}
+
+// ExecuteContext is Execute with an enforced time and output-size bound; see
+// RunBounded for the mechanics.
+func (p *PythonExecutor) ExecuteContext(ctx context.Context, moduleName string, args []string, opts ExecOptions) (map[string]interface{}, error) {
+	cmd := exec.Command(p.pythonPath, "-m", moduleName)
+	if p.modulePath != "" {
+		cmd.Env = append(os.Environ(), "PYTHONPATH="+p.modulePath)
+	}
+	cmd.Args = append(cmd.Args, args...)
+
+	output, err := RunBounded(ctx, cmd, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// exitCode reads the exit status cmd.Wait left behind, or -1 if the
+// process was killed before it ever produced one.
+func exitCode(cmd *exec.Cmd) int {
+	if cmd.ProcessState == nil {
+		return -1
+	}
+	return cmd.ProcessState.ExitCode()
+}
+
 // ExecuteInline executes a Python command directly
 func (p *PythonExecutor) ExecuteInline(code string) (map[string]interface{}, error) {
 	cmd := exec.Command(p.pythonPath, "-c", code)
@@ -107,28 +334,24 @@ func (p *PythonExecutor) ExecuteInline(code string) (map[string]interface{}, err
 	return result, nil
 }
 
-// ParseCommand parses a command line into parts
+// ParseCommand parses a command line into parts, respecting single/double
+// quotes, backslash escapes, and $VAR/${VAR} expansion (see Tokenize).
+// Pipe and redirect operators are returned as their own literal tokens
+// ("|", ">", ">>", "2>") rather than being specially interpreted — callers
+// that care about pipeline structure should use Tokenize/Pipeline directly.
+//
+// On a malformed line (unterminated quote, trailing backslash) this falls
+// back to a naive whitespace split rather than returning an error, since
+// ParseCommand's signature predates tokenizer errors and many existing
+// call sites don't check one.
 func ParseCommand(line string) []string {
-	var parts []string
-	var current strings.Builder
-	inQuotes := false
-
-	for i, ch := range line {
-		if ch == '"' {
-			inQuotes = !inQuotes
-		} else if ch == ' ' && !inQuotes {
-			if current.Len() > 0 {
-				parts = append(parts, current.String())
-				current.Reset()
-			}
-		} else {
-			current.WriteRune(ch)
-		}
-
-		if i == len(line)-1 && current.Len() > 0 {
-			parts = append(parts, current.String())
-		}
+	tokens, err := Tokenize(line)
+	if err != nil {
+		return strings.Fields(line)
+	}
+	parts := make([]string, len(tokens))
+	for i, t := range tokens {
+		parts[i] = t.Value
 	}
-
 	return parts
 }
@@ -0,0 +1,28 @@
+//go:build windows
+
+package trex_utils
+
+import "os/exec"
+
+// setProcessGroup is a no-op on windows: exec.Cmd has no process-group
+// concept there, so terminateProcessGroup/killProcessGroup fall back to
+// killing just the direct child.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup kills cmd's direct process. windows has no
+// SIGTERM-equivalent graceful stop for an arbitrary process, so this is
+// the same hard kill as killProcessGroup.
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+// killProcessGroup kills cmd's direct process.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
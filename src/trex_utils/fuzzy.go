@@ -0,0 +1,148 @@
+package trex_utils
+
+import "strings"
+
+const (
+	scoreMatch       = 16
+	scoreGapPenalty  = -3
+	bonusBoundary    = 10
+	bonusConsecutive = 8
+	bonusFirstRune   = 12
+	scoreUnmatched   = -1 << 30 // "-inf": pattern did not fully match
+)
+
+// isSeparator reports whether r marks a word boundary for bonus scoring:
+// path separators and punctuation commonly used between words. The
+// lower->upper transition of camelCase is detected by isUpperBoundary.
+func isSeparator(r rune) bool {
+	switch r {
+	case '/', '-', '_', ' ', '.', ':':
+		return true
+	}
+	return false
+}
+
+// FuzzyScore ranks how well pattern matches candidate as a subsequence,
+// using the same bonus-based algorithm used by fzf/fuzzy-history tools:
+// matches right after a separator or camelCase boundary, at the very
+// start of the string, or immediately following a prior match all score
+// higher than an isolated match deep in a gap. Returns scoreUnmatched
+// (effectively -inf) if pattern's runes are not all present in order.
+func FuzzyScore(pattern, candidate string) (score int, positions []int) {
+	patternRunes := []rune(pattern)
+	candidateRunes := []rune(candidate)
+
+	if len(patternRunes) == 0 {
+		return 0, nil
+	}
+
+	pi := 0
+	consecutive := 0
+	positions = make([]int, 0, len(patternRunes))
+
+	lowerPattern := []rune(strings.ToLower(pattern))
+
+	for ci, cr := range candidateRunes {
+		if pi >= len(patternRunes) {
+			break
+		}
+
+		crLower := []rune(strings.ToLower(string(cr)))[0]
+		if crLower != lowerPattern[pi] {
+			consecutive = 0
+			continue
+		}
+
+		positions = append(positions, ci)
+		score += scoreMatch
+
+		if ci == 0 {
+			score += bonusFirstRune
+		} else if isSeparator(candidateRunes[ci-1]) {
+			score += bonusBoundary
+		} else if isUpperBoundary(candidateRunes, ci) {
+			score += bonusBoundary
+		}
+
+		if consecutive > 0 {
+			score += bonusConsecutive
+		}
+		consecutive++
+		pi++
+	}
+
+	if pi < len(patternRunes) {
+		return scoreUnmatched, nil
+	}
+
+	// Penalize the total span the match occupies: a tight match beats a
+	// scattered one even when both hit every pattern rune.
+	if len(positions) > 1 {
+		span := positions[len(positions)-1] - positions[0] - (len(positions) - 1)
+		score += span * scoreGapPenalty
+	}
+
+	return score, positions
+}
+
+// isUpperBoundary reports whether candidate[idx] starts a camelCase word,
+// i.e. it's upper-case and the rune before it is lower-case.
+func isUpperBoundary(candidate []rune, idx int) bool {
+	if idx == 0 {
+		return false
+	}
+	cur := candidate[idx]
+	prev := candidate[idx-1]
+	return cur >= 'A' && cur <= 'Z' && prev >= 'a' && prev <= 'z'
+}
+
+// Match pairs a history candidate with its fuzzy-search score.
+type Match struct {
+	Text      string
+	Score     int
+	Positions []int
+}
+
+// Search ranks every history entry (most recent first on ties) against
+// pattern, returning at most limit matches sorted by descending score. An
+// empty pattern returns the most recent `limit` entries unscored.
+func (h *History) Search(pattern string, limit int) []Match {
+	entries := h.GetAll()
+
+	if pattern == "" {
+		var out []Match
+		for i := len(entries) - 1; i >= 0 && len(out) < limit; i-- {
+			out = append(out, Match{Text: entries[i]})
+		}
+		return out
+	}
+
+	var candidates []Match
+	seen := make(map[string]bool)
+	for i := len(entries) - 1; i >= 0; i-- {
+		cmd := entries[i]
+		if seen[cmd] {
+			continue
+		}
+		seen[cmd] = true
+
+		score, positions := FuzzyScore(pattern, cmd)
+		if score == scoreUnmatched {
+			continue
+		}
+		candidates = append(candidates, Match{Text: cmd, Score: score, Positions: positions})
+	}
+
+	// stable insertion sort by score descending; history is small enough
+	// that this beats pulling in sort for a rarely-hot path
+	for i := 1; i < len(candidates); i++ {
+		for j := i; j > 0 && candidates[j].Score > candidates[j-1].Score; j-- {
+			candidates[j], candidates[j-1] = candidates[j-1], candidates[j]
+		}
+	}
+
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
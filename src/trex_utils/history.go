@@ -2,99 +2,254 @@ package trex_utils
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
-// History manages command history
+// HistoryEntry is one recorded command invocation, including the context
+// the shell driver observed around it.
+type HistoryEntry struct {
+	ID         int64
+	Timestamp  time.Time
+	SessionID  string
+	CWD        string
+	ExitCode   int
+	DurationMs int64
+	Cmd        string
+}
+
+// HistoryQuery filters History.Query results. Zero-valued fields are
+// treated as "don't filter on this".
+type HistoryQuery struct {
+	SessionID    string
+	CWDPrefix    string
+	Since, Until time.Time
+	ExitCode     *int
+	Substring    string
+	Limit        int
+}
+
+// historyBackend is the storage contract History delegates to. The
+// SQLite-backed store (history_sqlite.go) and the flat-file store below
+// both implement it so History itself stays storage-agnostic.
+type historyBackend interface {
+	Append(entry HistoryEntry) error
+	All() ([]HistoryEntry, error)
+	Close() error
+}
+
+// History manages command history: dedup, session tagging, and querying,
+// backed by SQLite when available or a plain newline-delimited file
+// otherwise.
 type History struct {
-	commands []string
-	filePath string
-	maxSize  int
+	backend      historyBackend
+	sessionID    string
+	maxSize      int
+	ignorePrefix string
+
+	entries []HistoryEntry // in-memory cache, newest last
 }
 
-// NewHistory creates a new history manager
+// NewHistory creates a new history manager rooted at ~/.t-rex. It tries a
+// SQLite-backed store first (schema: id, ts, session_id, cwd, exit_code,
+// duration_ms, cmd) and falls back to the flat-file store used by older
+// versions of t-rex if SQLite can't be opened (e.g. missing driver, or a
+// read-only home directory).
 func NewHistory(maxSize int) *History {
 	h := &History{
-		commands: []string{},
-		maxSize:  maxSize,
+		maxSize:      maxSize,
+		sessionID:    newSessionID(),
+		ignorePrefix: " ",
 	}
 
 	homeDir, err := os.UserHomeDir()
 	if err == nil {
 		trexDir := filepath.Join(homeDir, ".t-rex")
 		os.MkdirAll(trexDir, 0755)
-		h.filePath = filepath.Join(trexDir, "history")
-		h.loadHistory()
+
+		if backend, err := openSQLiteHistory(filepath.Join(trexDir, "history.db")); err == nil {
+			h.backend = backend
+		} else {
+			h.backend = newFileHistory(filepath.Join(trexDir, "history"))
+		}
+	} else {
+		h.backend = newFileHistory("")
+	}
+
+	if entries, err := h.backend.All(); err == nil {
+		h.entries = entries
+		h.trim()
 	}
 
 	return h
 }
 
-// Add adds a command to history
-func (h *History) Add(cmd string) {
-	cmd = strings.TrimSpace(cmd)
-	if cmd == "" {
-		return
-	}
-	h.commands = append(h.commands, cmd)
-	if len(h.commands) > h.maxSize {
-		h.commands = h.commands[1:]
+func newSessionID() string {
+	return fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+}
+
+func (h *History) trim() {
+	if h.maxSize > 0 && len(h.entries) > h.maxSize {
+		h.entries = h.entries[len(h.entries)-h.maxSize:]
 	}
-	h.saveHistory()
 }
 
-// loadHistory loads history from file
-func (h *History) loadHistory() {
-	if h.filePath == "" {
+// Add records a command as a bare string, with no CWD/exit-code/duration
+// context. Kept for callers (scripts, `set`/`let` handling) that don't run
+// a full external command. Prefer AddEntry when that context is available.
+func (h *History) Add(cmd string) {
+	h.AddEntry(HistoryEntry{Cmd: cmd, Timestamp: time.Now()})
+}
+
+// AddEntry records a fully-populated entry. Consecutive duplicate commands
+// collapse into one (matching HISTCONTROL=ignoredups), and commands
+// beginning with h.ignorePrefix (default: a single space) are never
+// persisted, letting users keep one-off commands out of history by
+// indenting them.
+func (h *History) AddEntry(entry HistoryEntry) {
+	if strings.HasPrefix(entry.Cmd, h.ignorePrefix) {
 		return
 	}
+	entry.Cmd = strings.TrimSpace(entry.Cmd)
+	if entry.Cmd == "" {
+		return
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if entry.SessionID == "" {
+		entry.SessionID = h.sessionID
+	}
 
-	file, err := os.Open(h.filePath)
-	if err != nil {
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1].Cmd == entry.Cmd {
 		return
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		h.commands = append(h.commands, scanner.Text())
+	if err := h.backend.Append(entry); err != nil {
+		// Storage is best-effort: a failed write shouldn't break the shell,
+		// but the command still lives in the in-memory cache for this
+		// session's Up-arrow/Ctrl-R recall.
 	}
 
-	if len(h.commands) > h.maxSize {
-		h.commands = h.commands[len(h.commands)-h.maxSize:]
+	h.entries = append(h.entries, entry)
+	h.trim()
+}
+
+// GetAll returns every command in chronological order (oldest first),
+// matching the pre-SQLite API used by LineEditor's Up/Down recall.
+func (h *History) GetAll() []string {
+	cmds := make([]string, len(h.entries))
+	for i, e := range h.entries {
+		cmds[i] = e.Cmd
 	}
+	return cmds
 }
 
-// saveHistory saves history to file
-func (h *History) saveHistory() {
-	if h.filePath == "" {
-		return
+// GetLast returns the last n commands.
+func (h *History) GetLast(n int) []string {
+	all := h.GetAll()
+	if n > len(all) {
+		n = len(all)
 	}
+	return all[len(all)-n:]
+}
 
-	file, err := os.Create(h.filePath)
-	if err != nil {
-		return
+// Query filters recorded entries (newest first) by the given criteria.
+func (h *History) Query(q HistoryQuery) []HistoryEntry {
+	var out []HistoryEntry
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		e := h.entries[i]
+		if q.SessionID != "" && e.SessionID != q.SessionID {
+			continue
+		}
+		if q.CWDPrefix != "" && !strings.HasPrefix(e.CWD, q.CWDPrefix) {
+			continue
+		}
+		if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && e.Timestamp.After(q.Until) {
+			continue
+		}
+		if q.ExitCode != nil && e.ExitCode != *q.ExitCode {
+			continue
+		}
+		if q.Substring != "" && !strings.Contains(e.Cmd, q.Substring) {
+			continue
+		}
+		out = append(out, e)
+		if q.Limit > 0 && len(out) >= q.Limit {
+			break
+		}
 	}
-	defer file.Close()
+	return out
+}
 
-	writer := bufio.NewWriter(file)
-	for _, cmd := range h.commands {
-		writer.WriteString(cmd + "\n")
+// Stats returns a command -> invocation-count map, for surfacing
+// most-used commands.
+func (h *History) Stats() map[string]int {
+	stats := make(map[string]int)
+	for _, e := range h.entries {
+		stats[e.Cmd]++
 	}
-	writer.Flush()
+	return stats
 }
 
-// GetAll returns all commands
-func (h *History) GetAll() []string {
-	return h.commands
+// Close releases the underlying storage handle (a no-op for the flat-file
+// backend, a real db.Close() for SQLite).
+func (h *History) Close() error {
+	return h.backend.Close()
 }
 
-// GetLast returns the last n commands
-func (h *History) GetLast(n int) []string {
-	if n > len(h.commands) {
-		n = len(h.commands)
+// ────────────────────────────────────────────────
+// Flat-file backend (fallback when SQLite isn't available)
+// ────────────────────────────────────────────────
+
+// fileHistory stores one command per line, with no timestamp/session/cwd
+// metadata, matching the format older t-rex versions wrote.
+type fileHistory struct {
+	path string
+}
+
+func newFileHistory(path string) *fileHistory {
+	return &fileHistory{path: path}
+}
+
+func (f *fileHistory) Append(entry HistoryEntry) error {
+	if f.path == "" {
+		return nil
 	}
-	return h.commands[len(h.commands)-n:]
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(entry.Cmd + "\n")
+	return err
+}
+
+func (f *fileHistory) All() ([]HistoryEntry, error) {
+	if f.path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entries = append(entries, HistoryEntry{Cmd: scanner.Text()})
+	}
+	return entries, scanner.Err()
 }
+
+func (f *fileHistory) Close() error { return nil }
@@ -0,0 +1,74 @@
+package trex_utils
+
+import (
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go driver, no cgo toolchain required
+)
+
+// sqliteHistory stores entries in a local SQLite file, one row per
+// command, with full session/cwd/exit-code/duration metadata.
+type sqliteHistory struct {
+	db *sql.DB
+}
+
+const sqliteHistorySchema = `
+CREATE TABLE IF NOT EXISTS history (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	ts          INTEGER NOT NULL,
+	session_id  TEXT NOT NULL,
+	cwd         TEXT NOT NULL,
+	exit_code   INTEGER NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	cmd         TEXT NOT NULL
+);
+`
+
+func openSQLiteHistory(path string) (*sqliteHistory, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteHistorySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteHistory{db: db}, nil
+}
+
+func (s *sqliteHistory) Append(entry HistoryEntry) error {
+	_, err := s.db.Exec(
+		`INSERT INTO history (ts, session_id, cwd, exit_code, duration_ms, cmd) VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.Timestamp.UnixMilli(), entry.SessionID, entry.CWD, entry.ExitCode, entry.DurationMs, entry.Cmd,
+	)
+	return err
+}
+
+func (s *sqliteHistory) All() ([]HistoryEntry, error) {
+	rows, err := s.db.Query(`SELECT id, ts, session_id, cwd, exit_code, duration_ms, cmd FROM history ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var e HistoryEntry
+		var tsMillis int64
+		if err := rows.Scan(&e.ID, &tsMillis, &e.SessionID, &e.CWD, &e.ExitCode, &e.DurationMs, &e.Cmd); err != nil {
+			return nil, err
+		}
+		e.Timestamp = time.UnixMilli(tsMillis)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqliteHistory) Close() error {
+	return s.db.Close()
+}
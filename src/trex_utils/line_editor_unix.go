@@ -0,0 +1,124 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package trex_utils
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// termios mirrors the kernel's struct termios layout. Field widths and the
+// Cc array size differ across Linux/darwin/*BSD, but this layout (as used
+// by golang.org/x/term internally) matches all of them closely enough for
+// the flag bits we touch here.
+type termios struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Line   uint8
+	Cc     [32]uint8
+	Ispeed uint32
+	Ospeed uint32
+}
+
+const (
+	IGNBRK = 0000001
+	BRKINT = 0000002
+	PARMRK = 0000010
+	ISTRIP = 0000020
+	INLCR  = 0000040
+	IGNCR  = 0000100
+	ICRNL  = 0000200
+	IXON   = 0002000
+	OPOST  = 0000001
+	ECHO   = 0000010
+	ECHONL = 0000100
+	ICANON = 0000002
+	ISIG   = 0000001
+	IEXTEN = 0100000
+	CS8    = 0000060
+	CSIZE  = 0000060
+	PARENB = 0000400
+)
+
+// unixRawMode restores the terminal's prior termios settings on restore().
+type unixRawMode struct {
+	old termios
+}
+
+func (m *unixRawMode) restore() error {
+	return tcSet(&m.old)
+}
+
+func tcGet() (termios, error) {
+	var t termios
+	fd := int(os.Stdin.Fd())
+	_, _, e := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(fd), tcgetsNumber(), uintptr(unsafe.Pointer(&t)), 0, 0, 0)
+	if e != 0 {
+		return t, syscall.Errno(e)
+	}
+	return t, nil
+}
+
+func tcSet(t *termios) error {
+	fd := int(os.Stdin.Fd())
+	_, _, e := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(fd), tcsetsNumber(), uintptr(unsafe.Pointer(t)), 0, 0, 0)
+	if e != 0 {
+		return syscall.Errno(e)
+	}
+	return nil
+}
+
+// enableRawMode puts the controlling terminal into raw mode, matching the
+// classic termios "cfmakeraw" recipe, and returns a handle that restores
+// the previous settings when released.
+func enableRawMode() (rawMode, error) {
+	old, err := tcGet()
+	if err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Iflag &^= uint32(IGNBRK | BRKINT | PARMRK | ISTRIP | INLCR | IGNCR | ICRNL | IXON)
+	raw.Oflag &^= uint32(OPOST)
+	raw.Lflag &^= uint32(ECHO | ECHONL | ICANON | ISIG | IEXTEN)
+	raw.Cflag &^= uint32(CSIZE | PARENB)
+	raw.Cflag |= uint32(CS8)
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := tcSet(&raw); err != nil {
+		return nil, err
+	}
+
+	return &unixRawMode{old: old}, nil
+}
+
+// watchResize notifies ch (non-blocking) on every SIGWINCH so the line
+// editor can redraw at the current terminal width. It returns a function
+// that stops the watch.
+func watchResize(ch chan<- struct{}) func() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGWINCH)
+
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sig:
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			case <-stop:
+				signal.Stop(sig)
+				return
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
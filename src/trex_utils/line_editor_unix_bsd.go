@@ -0,0 +1,8 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package trex_utils
+
+// BSD-derived kernels (including darwin) expose termios via the TIOCGETA/
+// TIOCSETA ioctls rather than Linux's TCGETS/TCSETS.
+func tcgetsNumber() uintptr { return 0x40487413 } // TIOCGETA
+func tcsetsNumber() uintptr { return 0x80487414 } // TIOCSETA
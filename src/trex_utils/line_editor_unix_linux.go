@@ -0,0 +1,7 @@
+//go:build linux
+
+package trex_utils
+
+// Linux ioctl request numbers for reading/writing termios.
+func tcgetsNumber() uintptr { return 0x5401 } // TCGETS
+func tcsetsNumber() uintptr { return 0x5402 } // TCSETS
@@ -0,0 +1,76 @@
+//go:build windows
+
+package trex_utils
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// windowsRawMode restores the console's prior input mode on restore().
+type windowsRawMode struct {
+	handle  windows.Handle
+	oldMode uint32
+}
+
+func (m *windowsRawMode) restore() error {
+	return windows.SetConsoleMode(m.handle, m.oldMode)
+}
+
+// enableRawMode disables line buffering and local echo on the console
+// input buffer, and turns on VT input so arrow keys / Ctrl sequences
+// arrive as the same ANSI escape sequences the Unix path already parses.
+func enableRawMode() (rawMode, error) {
+	handle := windows.Handle(windows.Stdin)
+
+	var oldMode uint32
+	if err := windows.GetConsoleMode(handle, &oldMode); err != nil {
+		return nil, err
+	}
+
+	newMode := oldMode
+	newMode &^= windows.ENABLE_LINE_INPUT | windows.ENABLE_ECHO_INPUT
+	newMode |= windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+
+	if err := windows.SetConsoleMode(handle, newMode); err != nil {
+		return nil, err
+	}
+
+	return &windowsRawMode{handle: handle, oldMode: oldMode}, nil
+}
+
+// watchResize polls the console screen buffer size, since Windows consoles
+// have no SIGWINCH equivalent. A short poll interval keeps redraws feeling
+// immediate without burning much CPU while the prompt is idle.
+func watchResize(ch chan<- struct{}) func() {
+	stop := make(chan struct{})
+	go func() {
+		handle := windows.Handle(windows.Stdout)
+		var last windows.ConsoleScreenBufferInfo
+		_ = windows.GetConsoleScreenBufferInfo(handle, &last)
+
+		ticker := time.NewTicker(150 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				var info windows.ConsoleScreenBufferInfo
+				if err := windows.GetConsoleScreenBufferInfo(handle, &info); err == nil {
+					if info.Size.X != last.Size.X || info.Size.Y != last.Size.Y {
+						last = info
+						select {
+						case ch <- struct{}{}:
+						default:
+						}
+					}
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
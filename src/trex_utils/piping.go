@@ -2,8 +2,14 @@ package trex_utils
 
 import (
 	"fmt"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
 )
 
 // Pipeline represents a piped command sequence
@@ -11,33 +17,177 @@ type Pipeline struct {
 	commands []string
 }
 
-// NewPipeline creates a new pipeline from command line
+// NewPipeline creates a new pipeline from command line, splitting on
+// unquoted "|" stage separators (via SplitUnquotedPipes) so a quoted pipe
+// character (grep "a|b") stays inside its stage instead of splitting the
+// pipeline. Each stage keeps its original, still-quoted text — callers
+// re-tokenize a stage themselves (via ParseCommand) to get its argv, same
+// as before NewPipeline existed.
 func NewPipeline(line string) *Pipeline {
-	parts := strings.Split(line, "|")
-	var commands []string
-	for _, part := range parts {
-		commands = append(commands, strings.TrimSpace(part))
-	}
-	return &Pipeline{commands: commands}
+	return &Pipeline{commands: SplitUnquotedPipes(line)}
+}
+
+// Stages returns the pipeline's stage texts in order, first stage included.
+func (p *Pipeline) Stages() []string {
+	return p.commands
 }
 
-// HasPipe checks if line contains pipe
+// HasPipe reports whether line contains an unquoted "|" pipe separator, so
+// `grep "a|b"` isn't mistaken for a two-stage pipeline.
 func HasPipe(line string) bool {
-	return strings.Contains(line, "|")
+	return len(SplitUnquotedPipes(line)) > 1
 }
 
-// SelectFields filters JSON data to specified fields
+// SelectFields filters JSON data to specified fields, resolved through
+// EvalPath so a field may be a bare key ("name") or a dotted path into
+// nested data ("metadata.name", "tags[0]").
 func SelectFields(data map[string]interface{}, fields []string) map[string]interface{} {
 	result := make(map[string]interface{})
 	for _, field := range fields {
 		field = strings.TrimSpace(field)
-		if val, exists := data[field]; exists {
+		if val, err := EvalPath(data, field); err == nil && val != nil {
 			result[field] = val
 		}
 	}
 	return result
 }
 
+// pathOp is one step of a parsed EvalPath expression.
+type pathOp struct {
+	kind        byte // 'f' field, 'i' index, 'w' wildcard, 'q' filter
+	field       string
+	index       int
+	filterField string
+	filterValue string
+}
+
+// parsePathTokens parses a small JSONPath-like expression into a sequence
+// of steps: "$.a.b[0].c", "items[*].name", and
+// "items[?(@.status=="ok")].name" filters. It's intentionally a subset of
+// real JSONPath — just enough for columns/select to reach into nested
+// module output — not a general-purpose implementation.
+func parsePathTokens(expr string) ([]pathOp, error) {
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+	s = strings.TrimPrefix(s, ".")
+
+	var ops []pathOp
+	i, n := 0, len(s)
+	for i < n {
+		start := i
+		for i < n && s[i] != '.' && s[i] != '[' {
+			i++
+		}
+		if i > start {
+			ops = append(ops, pathOp{kind: 'f', field: s[start:i]})
+		}
+
+		for i < n && s[i] == '[' {
+			closeIdx := strings.IndexByte(s[i:], ']')
+			if closeIdx < 0 {
+				return nil, fmt.Errorf("path: unterminated '[' in %q", expr)
+			}
+			inner := s[i+1 : i+closeIdx]
+			i += closeIdx + 1
+
+			switch {
+			case inner == "*":
+				ops = append(ops, pathOp{kind: 'w'})
+			case strings.HasPrefix(inner, "?("):
+				cond := strings.TrimSuffix(strings.TrimPrefix(inner, "?("), ")")
+				parts := strings.SplitN(cond, "==", 2)
+				if len(parts) != 2 {
+					return nil, fmt.Errorf("path: unsupported filter %q", inner)
+				}
+				field := strings.TrimPrefix(strings.TrimSpace(parts[0]), "@.")
+				value := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+				ops = append(ops, pathOp{kind: 'q', filterField: field, filterValue: value})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("path: invalid index %q", inner)
+				}
+				ops = append(ops, pathOp{kind: 'i', index: idx})
+			}
+		}
+
+		if i < n && s[i] == '.' {
+			i++
+		}
+	}
+	return ops, nil
+}
+
+// EvalPath evaluates a JSONPath-like expression (see parsePathTokens)
+// against data, returning nil when any step doesn't resolve rather than
+// an error — a missing field is a normal outcome for sparse records, not
+// a failure.
+func EvalPath(data interface{}, expr string) (interface{}, error) {
+	ops, err := parsePathTokens(expr)
+	if err != nil {
+		return nil, err
+	}
+	return applyPathOps(data, ops)
+}
+
+func applyPathOps(data interface{}, ops []pathOp) (interface{}, error) {
+	cur := data
+	for i, op := range ops {
+		switch op.kind {
+		case 'f':
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			cur = m[op.field]
+
+		case 'i':
+			arr, ok := cur.([]interface{})
+			if !ok || op.index < 0 || op.index >= len(arr) {
+				return nil, nil
+			}
+			cur = arr[op.index]
+
+		case 'w':
+			arr, ok := cur.([]interface{})
+			if !ok {
+				return nil, nil
+			}
+			rest := ops[i+1:]
+			out := make([]interface{}, 0, len(arr))
+			for _, item := range arr {
+				v, err := applyPathOps(item, rest)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			}
+			return out, nil
+
+		case 'q':
+			arr, ok := cur.([]interface{})
+			if !ok {
+				return nil, nil
+			}
+			rest := ops[i+1:]
+			var out []interface{}
+			for _, item := range arr {
+				obj, ok := item.(map[string]interface{})
+				if !ok || fmt.Sprintf("%v", obj[op.filterField]) != op.filterValue {
+					continue
+				}
+				v, err := applyPathOps(item, rest)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			}
+			return out, nil
+		}
+	}
+	return cur, nil
+}
+
 // FilterArray filters an array of objects to specified fields
 func FilterArray(arr []interface{}, fields []string) []map[string]interface{} {
 	var results []map[string]interface{}
@@ -193,105 +343,196 @@ func SplitCommaFields(s string) []string {
 	return result
 }
 
-// TablePrint formats data as a pretty table — vertical for single object, horizontal for array
+// TableOptions controls how TablePrintWithOptions renders a table. The
+// zero value is usable (cells truncated to a width derived from the
+// terminal, "null" nil text, unsorted columns) but most callers want
+// DefaultTableOptions.
+type TableOptions struct {
+	MaxColWidth int    // display-column cap per cell before Wrap/Truncate kicks in; 0 = derive from terminal width
+	Wrap        bool   // wrap overflowing cells onto extra lines instead of truncating with "..."
+	NullString  string // text shown for a nil field value; "" means "null"
+	SortColumns bool   // sort field/column names alphabetically
+	Truncate    bool   // truncate overflowing cells with "..."; the default when Wrap is false
+	NoColor     bool   // skip header coloring, for pipe-safe output
+}
+
+// DefaultTableOptions mirrors TablePrint's historical behavior: sorted
+// columns, truncated (not wrapped) overflow sized to the terminal.
+func DefaultTableOptions() TableOptions {
+	return TableOptions{SortColumns: true, NullString: "null", Truncate: true}
+}
+
+// minColWidth is the floor columnLimit will shrink a column to, however
+// narrow the terminal, so a single-digit field isn't wrapped into an
+// unreadable staircase.
+const minColWidth = 8
+
+// terminalWidth returns stdout's current width, falling back to 80 when
+// stdout isn't a terminal (piped into another command, or there's no
+// controlling tty at all) or the ioctl fails.
+func terminalWidth() int {
+	w, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 {
+		return 80
+	}
+	return w
+}
+
+// columnLimit resolves the per-cell display-width cap: an explicit
+// opts.MaxColWidth, or an even share of the terminal width across
+// numColumns columns, so a wide terminal doesn't force wrapping/truncation
+// that a narrow one would genuinely need. This is an approximation — it
+// doesn't know the other columns' actual rendered widths ahead of time —
+// but it keeps tt usable instead of running off the edge of the screen.
+func columnLimit(opts TableOptions, numColumns int) int {
+	if opts.MaxColWidth > 0 {
+		return opts.MaxColWidth
+	}
+	if numColumns < 1 {
+		numColumns = 1
+	}
+	per := (terminalWidth() - numColumns*4) / numColumns
+	if per < minColWidth {
+		per = minColWidth
+	}
+	return per
+}
+
+// cellString renders a single field value as display text, substituting
+// opts.NullString (default "null") for a nil value.
+func cellString(value interface{}, opts TableOptions) string {
+	if value == nil {
+		if opts.NullString != "" {
+			return opts.NullString
+		}
+		return "null"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// wrapOrTruncateCell renders value as one or more display lines, each no
+// wider than width display columns (go-runewidth aware, so a wide CJK
+// rune is never split in half). width <= 0 means no limit at all.
+func wrapOrTruncateCell(value interface{}, width int, opts TableOptions) []string {
+	s := cellString(value, opts)
+	if width <= 0 || runewidth.StringWidth(s) <= width {
+		return []string{s}
+	}
+	if !opts.Wrap {
+		return []string{runewidth.Truncate(s, width, "...")}
+	}
+
+	var lines []string
+	for runewidth.StringWidth(s) > width {
+		chunk := runewidth.Truncate(s, width, "")
+		if chunk == "" {
+			break // width too small even for a single rune; avoid looping forever
+		}
+		lines = append(lines, chunk)
+		s = s[len(chunk):]
+	}
+	if s != "" {
+		lines = append(lines, s)
+	}
+	return lines
+}
+
+// colorizeHeaderLine wraps the first (header) line of a tabwriter-rendered
+// table in color. It operates on the already-aligned output rather than
+// coloring individual cells before they reach tabwriter, since ANSI escape
+// codes would otherwise count toward tabwriter's column-width measurement
+// and throw off alignment.
+func colorizeHeaderLine(rendered string, noColor bool) string {
+	lines := strings.Split(strings.TrimRight(rendered, "\n"), "\n")
+	if !noColor && len(lines) > 0 {
+		lines[0] = ColoredText(lines[0], Cyan)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// TablePrint formats data as a pretty table — vertical for a single
+// object, horizontal for an array — using DefaultTableOptions.
 func TablePrint(data interface{}) string {
+	return TablePrintWithOptions(data, DefaultTableOptions())
+}
+
+// TablePrintWithOptions is TablePrint with explicit rendering options; see
+// TableOptions.
+func TablePrintWithOptions(data interface{}, opts TableOptions) string {
 	switch v := data.(type) {
 	case map[string]interface{}:
 		// Single object → vertical table (one row per key-value)
-		return formatMapAsVerticalTable(v)
+		return formatMapAsVerticalTable(v, opts)
 	case []interface{}:
 		// Array of objects → classic horizontal table
-		return formatTable(v)
+		return formatTable(v, opts)
 	default:
 		return PrettyPrint(data)
 	}
 }
 
-// formatMapAsVerticalTable prints a single map as a vertical key-value table
-func formatMapAsVerticalTable(m map[string]interface{}) string {
+// formatMapAsVerticalTable prints a single map as a vertical key-value
+// table, aligned with text/tabwriter.
+func formatMapAsVerticalTable(m map[string]interface{}, opts TableOptions) string {
 	if len(m) == 0 {
 		return "(empty)\n"
 	}
 
-	var result strings.Builder
 	var keys []string
-	maxKeyLen := 0
-
-	// Collect and sort keys for consistent order
 	for key := range m {
 		keys = append(keys, key)
-		if len(key) > maxKeyLen {
-			maxKeyLen = len(key)
-		}
 	}
-	sort.Strings(keys)
-
-	// Top border
-	result.WriteString("┌")
-	result.WriteString(strings.Repeat("─", maxKeyLen+2))
-	result.WriteString("┬")
-	result.WriteString(strings.Repeat("─", 42))
-	result.WriteString("┐\n")
-
-	// Header
-	result.WriteString("│ ")
-	result.WriteString(padRight("Field", maxKeyLen))
-	result.WriteString(" │ ")
-	result.WriteString(padRight("Value", 40))
-	result.WriteString(" │\n")
-
-	// Separator
-	result.WriteString("├")
-	result.WriteString(strings.Repeat("─", maxKeyLen+2))
-	result.WriteString("┼")
-	result.WriteString(strings.Repeat("─", 42))
-	result.WriteString("┤\n")
-
-	// One row per field
+	if opts.SortColumns {
+		sort.Strings(keys)
+	}
+
+	valueWidth := columnLimit(opts, 1)
+
+	var buf strings.Builder
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Field\tValue")
+	fmt.Fprintln(tw, "-----\t-----")
+
 	for _, key := range keys {
-		valStr := fmt.Sprintf("%v", m[key])
-		if len(valStr) > 40 {
-			valStr = valStr[:37] + "..."
+		lines := wrapOrTruncateCell(m[key], valueWidth, opts)
+		for i, line := range lines {
+			if i == 0 {
+				fmt.Fprintf(tw, "%s\t%s\n", key, line)
+			} else {
+				fmt.Fprintf(tw, "\t%s\n", line)
+			}
 		}
-
-		result.WriteString("│ ")
-		result.WriteString(padRight(key, maxKeyLen))
-		result.WriteString(" │ ")
-		result.WriteString(padRight(valStr, 40))
-		result.WriteString(" │\n")
 	}
 
-	// Bottom border
-	result.WriteString("└")
-	result.WriteString(strings.Repeat("─", maxKeyLen+2))
-	result.WriteString("┴")
-	result.WriteString(strings.Repeat("─", 42))
-	result.WriteString("┘\n")
-
-	return result.String()
+	tw.Flush()
+	return colorizeHeaderLine(buf.String(), opts.NoColor)
 }
 
-// formatTable formats array of objects as horizontal table
-func formatTable(arr []interface{}) string {
+// formatTable formats an array of objects as a horizontal table, aligned
+// with text/tabwriter. Columns are collected as each object's keys are
+// first seen, in Go map iteration order within an object, so their order
+// is not stable unless opts.SortColumns sorts them; cells that don't fit
+// columnLimit's per-column cap are wrapped or truncated per opts.
+func formatTable(arr []interface{}, opts TableOptions) string {
 	if len(arr) == 0 {
 		return "(empty)\n"
 	}
 
 	var items []map[string]interface{}
 	var columns []string
-	columnWidths := make(map[string]int)
+	seenColumn := make(map[string]bool)
 
 	for _, item := range arr {
-		if obj, ok := item.(map[string]interface{}); ok {
-			items = append(items, obj)
-			for key := range obj {
-				if !contains(columns, key) {
-					columns = append(columns, key)
-				}
-				width := len(fmt.Sprintf("%v", obj[key]))
-				if width > columnWidths[key] {
-					columnWidths[key] = width
-				}
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		items = append(items, obj)
+		for key := range obj {
+			if !seenColumn[key] {
+				seenColumn[key] = true
+				columns = append(columns, key)
 			}
 		}
 	}
@@ -300,66 +541,75 @@ func formatTable(arr []interface{}) string {
 		return PrettyPrint(arr)
 	}
 
-	sort.Strings(columns)
-
-	for _, col := range columns {
-		if columnWidths[col] < len(col) {
-			columnWidths[col] = len(col)
-		}
+	if opts.SortColumns {
+		sort.Strings(columns)
 	}
 
-	var result strings.Builder
+	return renderRowsTable(items, columns, opts)
+}
 
-	// Top border
-	result.WriteString("┌")
-	for i, col := range columns {
-		result.WriteString(strings.Repeat("─", columnWidths[col]+2))
-		if i < len(columns)-1 {
-			result.WriteString("┬")
-		}
-	}
-	result.WriteString("┐\n")
+// renderRowsTable is the shared tabwriter-based row renderer behind both
+// formatTable (which discovers and optionally sorts its own columns) and
+// TablePrintOrdered (which takes an explicit, pre-ordered column list).
+func renderRowsTable(items []map[string]interface{}, columns []string, opts TableOptions) string {
+	colWidth := columnLimit(opts, len(columns))
 
-	// Header
-	result.WriteString("│")
-	for _, col := range columns {
-		result.WriteString(" " + padRight(col, columnWidths[col]) + " ")
-		result.WriteString("│")
-	}
-	result.WriteString("\n")
+	var buf strings.Builder
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
 
-	// Separator
-	result.WriteString("├")
+	rule := make([]string, len(columns))
 	for i, col := range columns {
-		result.WriteString(strings.Repeat("─", columnWidths[col]+2))
-		if i < len(columns)-1 {
-			result.WriteString("┼")
-		}
+		rule[i] = strings.Repeat("-", runewidth.StringWidth(col))
 	}
-	result.WriteString("┤\n")
+	fmt.Fprintln(tw, strings.Join(rule, "\t"))
 
-	// Data rows
 	for _, item := range items {
-		result.WriteString("│")
-		for _, col := range columns {
-			val := fmt.Sprintf("%v", item[col])
-			result.WriteString(" " + padRight(val, columnWidths[col]) + " ")
-			result.WriteString("│")
+		wrapped := make([][]string, len(columns))
+		maxLines := 1
+		for i, col := range columns {
+			wrapped[i] = wrapOrTruncateCell(item[col], colWidth, opts)
+			if len(wrapped[i]) > maxLines {
+				maxLines = len(wrapped[i])
+			}
+		}
+		for line := 0; line < maxLines; line++ {
+			row := make([]string, len(columns))
+			for i := range columns {
+				if line < len(wrapped[i]) {
+					row[i] = wrapped[i][line]
+				}
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
 		}
-		result.WriteString("\n")
 	}
 
-	// Bottom border
-	result.WriteString("└")
-	for i, col := range columns {
-		result.WriteString(strings.Repeat("─", columnWidths[col]+2))
-		if i < len(columns)-1 {
-			result.WriteString("┴")
+	tw.Flush()
+	return colorizeHeaderLine(buf.String(), opts.NoColor)
+}
+
+// TablePrintOrdered renders rows (each a map[string]interface{}) as a
+// horizontal table using exactly the given column order, rather than
+// discovering and (optionally) sorting columns from the data the way
+// TablePrint does. Used by the `columns` pipeline stage, where column
+// order is part of the user's request and shouldn't be reshuffled.
+func TablePrintOrdered(rows []interface{}, columns []string, opts TableOptions) string {
+	if len(rows) == 0 {
+		return "(empty)\n"
+	}
+
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if obj, ok := row.(map[string]interface{}); ok {
+			items = append(items, obj)
 		}
 	}
-	result.WriteString("┘\n")
+	if len(items) == 0 {
+		return PrettyPrint(rows)
+	}
 
-	return result.String()
+	return renderRowsTable(items, columns, opts)
 }
 
 func formatMapAsTable(m map[string]interface{}) string {
@@ -0,0 +1,141 @@
+package trex_utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	ansiBoldCyan = "\033[1;36m"
+	ansiReset2   = "\033[0m"
+)
+
+// reverseSearch drives an interactive "(reverse-i-search)" prompt: it
+// reuses History.Search to rank candidates against the pattern typed so
+// far, Up/Down cycle through the ranked matches, Enter accepts the
+// highlighted candidate, and Esc cancels back to the caller's current
+// line. Returns "" (with no error) when the user cancels.
+func (le *LineEditor) reverseSearch(reader *bufio.Reader) (string, error) {
+	var pattern []rune
+	matches := le.history.Search("", 50)
+	selected := 0
+
+	redraw := func() {
+		fmt.Print("\r\033[K")
+		fmt.Printf("(reverse-i-search)`%s`: ", string(pattern))
+		if selected < len(matches) {
+			fmt.Print(highlightMatch(matches[selected]))
+		}
+	}
+	redraw()
+
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case r == 27: // Esc, or the start of an arrow-key escape sequence
+			b1, err := reader.ReadByte()
+			if err != nil || b1 != '[' {
+				fmt.Println()
+				return "", nil
+			}
+			b2, err := reader.ReadByte()
+			if err != nil {
+				fmt.Println()
+				return "", nil
+			}
+			switch b2 {
+			case 'A': // Up: older match
+				if selected < len(matches)-1 {
+					selected++
+				}
+			case 'B': // Down: newer match
+				if selected > 0 {
+					selected--
+				}
+			default:
+				fmt.Println()
+				return "", nil
+			}
+
+		case r == '\n' || r == '\r':
+			fmt.Println()
+			if selected < len(matches) {
+				return matches[selected].Text, nil
+			}
+			return "", nil
+
+		case r == 3: // Ctrl-C
+			fmt.Println()
+			return "", fmt.Errorf("interrupted")
+
+		case r == 127 || r == 8: // Backspace
+			if len(pattern) > 0 {
+				pattern = pattern[:len(pattern)-1]
+			}
+
+		case r == 18: // Ctrl-R again: step to the next older match
+			if selected < len(matches)-1 {
+				selected++
+			}
+
+		case r >= 32 && r != 127:
+			pattern = append(pattern, r)
+
+		default:
+			continue
+		}
+
+		matches = le.history.Search(string(pattern), 50)
+		if selected >= len(matches) {
+			selected = 0
+		}
+		redraw()
+	}
+}
+
+// InteractivePick runs the same fuzzy incremental search used by Ctrl-R as
+// a standalone picker (for the "history --search" command), entering raw
+// mode itself rather than relying on an in-progress ReadLine call.
+func (le *LineEditor) InteractivePick() (string, error) {
+	raw, err := enableRawMode()
+	if err != nil {
+		return "", fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	defer raw.restore()
+
+	reader := bufio.NewReader(os.Stdin)
+	selected, err := le.reverseSearch(reader)
+	fmt.Println()
+	return selected, err
+}
+
+// highlightMatch renders a Match's candidate text with every rune at a
+// matched position set in bold cyan.
+func highlightMatch(m Match) string {
+	if len(m.Positions) == 0 {
+		return m.Text
+	}
+
+	matched := make(map[int]bool, len(m.Positions))
+	for _, p := range m.Positions {
+		matched[p] = true
+	}
+
+	var b strings.Builder
+	for i, r := range []rune(m.Text) {
+		if matched[i] {
+			b.WriteString(ansiBoldCyan)
+			b.WriteRune(r)
+			b.WriteString(ansiReset2)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
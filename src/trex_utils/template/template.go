@@ -0,0 +1,466 @@
+// Package template implements a small Handlebars/Mustache-style renderer
+// used by the `template` pipeline stage and the `output_template` .trexrc
+// setting. It supports {{var}} with dotted paths, {{{var}}} for unescaped
+// output, {{#each list}}...{{/each}} (with {{@index}} / {{.}}), {{#if x}}
+// ... {{else}} ... {{/if}}, and a handful of helpers: upper, lower, json,
+// default X Y, join list sep.
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Template is a compiled template, ready to Render against any number of
+// contexts.
+type Template struct {
+	nodes []node
+}
+
+// Compile parses src into a Template.
+func Compile(src string) (*Template, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	nodes, err := p.parseUntil()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("template: unexpected closing tag {{%s}}", p.toks[p.pos].text)
+	}
+	return &Template{nodes: nodes}, nil
+}
+
+// Render executes the template against ctx, which is typically a
+// map[string]interface{} (a module's "output" or its whole result map).
+func (t *Template) Render(ctx interface{}) (string, error) {
+	var b strings.Builder
+	root := &scope{data: ctx, index: -1}
+	if err := renderNodes(&b, t.nodes, root); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ────────────────────────────────────────────────
+// AST
+// ────────────────────────────────────────────────
+
+type nodeKind int
+
+const (
+	nodeText nodeKind = iota
+	nodeVar
+	nodeEach
+	nodeIf
+)
+
+type node struct {
+	kind nodeKind
+	text string // nodeText
+	expr string // nodeVar/nodeEach/nodeIf: the tag's inner expression
+	raw  bool   // nodeVar: {{{ }}} rather than {{ }}
+
+	body     []node // nodeEach, nodeIf's "then"
+	elseBody []node // nodeIf's "else"
+}
+
+// ────────────────────────────────────────────────
+// Tokenizer
+// ────────────────────────────────────────────────
+
+type rawTok struct {
+	isTag bool
+	raw   bool // {{{ }}} rather than {{ }}
+	text  string
+}
+
+func tokenize(src string) ([]rawTok, error) {
+	var toks []rawTok
+	runes := []rune(src)
+	i := 0
+
+	for i < len(runes) {
+		start := i
+		for i < len(runes) && !(runes[i] == '{' && i+1 < len(runes) && runes[i+1] == '{') {
+			i++
+		}
+		if i > start {
+			toks = append(toks, rawTok{text: string(runes[start:i])})
+		}
+		if i >= len(runes) {
+			break
+		}
+
+		raw := i+2 < len(runes) && runes[i+2] == '{'
+		openLen := 2
+		closeTag := "}}"
+		if raw {
+			openLen = 3
+			closeTag = "}}}"
+		}
+		i += openLen
+
+		end := runeIndex(runes, i, closeTag)
+		if end < 0 {
+			open := "{{"
+			if raw {
+				open = "{{{"
+			}
+			return nil, fmt.Errorf("template: unterminated %q", open)
+		}
+		toks = append(toks, rawTok{isTag: true, raw: raw, text: strings.TrimSpace(string(runes[i:end]))})
+		i = end + len(closeTag)
+	}
+
+	return toks, nil
+}
+
+func runeIndex(runes []rune, from int, sub string) int {
+	subRunes := []rune(sub)
+	for i := from; i+len(subRunes) <= len(runes); i++ {
+		match := true
+		for j, r := range subRunes {
+			if runes[i+j] != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// ────────────────────────────────────────────────
+// Parser
+// ────────────────────────────────────────────────
+
+type parser struct {
+	toks []rawTok
+	pos  int
+}
+
+// parseUntil consumes nodes up to (but not including) a block-closing tag
+// ("/each", "/if", "else") or the end of input, so the caller can inspect
+// which one stopped it.
+func (p *parser) parseUntil() ([]node, error) {
+	var nodes []node
+
+	for p.pos < len(p.toks) {
+		t := p.toks[p.pos]
+		if !t.isTag {
+			nodes = append(nodes, node{kind: nodeText, text: t.text})
+			p.pos++
+			continue
+		}
+
+		switch {
+		case t.text == "/each" || t.text == "/if" || t.text == "else":
+			return nodes, nil
+
+		case strings.HasPrefix(t.text, "#each "):
+			p.pos++
+			expr := strings.TrimSpace(strings.TrimPrefix(t.text, "#each "))
+			body, err := p.parseUntil()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectClose("/each"); err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node{kind: nodeEach, expr: expr, body: body})
+
+		case strings.HasPrefix(t.text, "#if "):
+			p.pos++
+			expr := strings.TrimSpace(strings.TrimPrefix(t.text, "#if "))
+			thenBody, err := p.parseUntil()
+			if err != nil {
+				return nil, err
+			}
+			var elseBody []node
+			if p.pos < len(p.toks) && p.toks[p.pos].isTag && p.toks[p.pos].text == "else" {
+				p.pos++
+				elseBody, err = p.parseUntil()
+				if err != nil {
+					return nil, err
+				}
+			}
+			if err := p.expectClose("/if"); err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node{kind: nodeIf, expr: expr, body: thenBody, elseBody: elseBody})
+
+		default:
+			nodes = append(nodes, node{kind: nodeVar, expr: t.text, raw: t.raw})
+			p.pos++
+		}
+	}
+
+	return nodes, nil
+}
+
+func (p *parser) expectClose(tag string) error {
+	if p.pos >= len(p.toks) || !p.toks[p.pos].isTag || p.toks[p.pos].text != tag {
+		return fmt.Errorf("template: expected {{%s}}", tag)
+	}
+	p.pos++
+	return nil
+}
+
+// ────────────────────────────────────────────────
+// Rendering
+// ────────────────────────────────────────────────
+
+// scope resolves {{var}} paths: first against its own data, falling back
+// to its parent so {{#each}} bodies can still reach outer fields.
+type scope struct {
+	data   interface{}
+	index  int
+	parent *scope
+}
+
+func (s *scope) resolve(path string) (interface{}, bool) {
+	switch path {
+	case ".":
+		return s.data, true
+	case "@index":
+		return s.index, true
+	}
+	if v, ok := lookupPath(s.data, path); ok {
+		return v, true
+	}
+	if s.parent != nil {
+		return s.parent.resolve(path)
+	}
+	return nil, false
+}
+
+func lookupPath(data interface{}, path string) (interface{}, bool) {
+	cur := data
+	for _, part := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			val, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			cur = val
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			cur = v[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func renderNodes(b *strings.Builder, nodes []node, sc *scope) error {
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeText:
+			b.WriteString(n.text)
+
+		case nodeVar:
+			val, err := evalExpr(n.expr, sc)
+			if err != nil {
+				return err
+			}
+			s := toString(val)
+			if !n.raw {
+				s = htmlEscape(s)
+			}
+			b.WriteString(s)
+
+		case nodeEach:
+			val, err := evalExpr(n.expr, sc)
+			if err != nil {
+				return err
+			}
+			list, ok := val.([]interface{})
+			if !ok {
+				continue
+			}
+			for i, item := range list {
+				child := &scope{data: item, index: i, parent: sc}
+				if err := renderNodes(b, n.body, child); err != nil {
+					return err
+				}
+			}
+
+		case nodeIf:
+			val, err := evalExpr(n.expr, sc)
+			if err != nil {
+				return err
+			}
+			if truthy(val) {
+				if err := renderNodes(b, n.body, sc); err != nil {
+					return err
+				}
+			} else if n.elseBody != nil {
+				if err := renderNodes(b, n.elseBody, sc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ────────────────────────────────────────────────
+// Expressions: a bare path, or "helper arg1 arg2 ..."
+// ────────────────────────────────────────────────
+
+type argTok struct {
+	text   string
+	quoted bool
+}
+
+func splitArgs(s string) []argTok {
+	var args []argTok
+	var cur strings.Builder
+	quoted := false
+	inQuote := rune(0)
+
+	flush := func() {
+		if cur.Len() > 0 || quoted {
+			args = append(args, argTok{text: cur.String(), quoted: quoted})
+			cur.Reset()
+			quoted = false
+		}
+	}
+
+	for _, r := range s {
+		switch {
+		case inQuote != 0:
+			if r == inQuote {
+				inQuote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			inQuote = r
+			quoted = true
+		case r == ' ' || r == '\t':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+
+	return args
+}
+
+func resolveArg(t argTok, sc *scope) interface{} {
+	if t.quoted {
+		return t.text
+	}
+	if v, ok := sc.resolve(t.text); ok {
+		return v
+	}
+	return t.text
+}
+
+func evalExpr(expr string, sc *scope) (interface{}, error) {
+	toks := splitArgs(expr)
+	if len(toks) == 0 {
+		return nil, nil
+	}
+	if len(toks) == 1 {
+		return resolveArg(toks[0], sc), nil
+	}
+
+	helper := toks[0].text
+	args := toks[1:]
+
+	switch helper {
+	case "upper":
+		return strings.ToUpper(toString(resolveArg(args[0], sc))), nil
+
+	case "lower":
+		return strings.ToLower(toString(resolveArg(args[0], sc))), nil
+
+	case "json":
+		b, err := json.Marshal(resolveArg(args[0], sc))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+
+	case "default":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("template: default needs 2 args, got %d", len(args))
+		}
+		if v := resolveArg(args[0], sc); !isEmpty(v) {
+			return v, nil
+		}
+		return resolveArg(args[1], sc), nil
+
+	case "join":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("template: join needs 2 args, got %d", len(args))
+		}
+		list, ok := resolveArg(args[0], sc).([]interface{})
+		if !ok {
+			return toString(resolveArg(args[0], sc)), nil
+		}
+		sep := toString(resolveArg(args[1], sc))
+		parts := make([]string, len(list))
+		for i, v := range list {
+			parts[i] = toString(v)
+		}
+		return strings.Join(parts, sep), nil
+
+	default:
+		return nil, fmt.Errorf("template: unknown helper %q", helper)
+	}
+}
+
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+func isEmpty(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return true
+	case string:
+		return x == ""
+	case bool:
+		return !x
+	}
+	return false
+}
+
+func truthy(v interface{}) bool {
+	return !isEmpty(v)
+}
+
+func htmlEscape(s string) string {
+	r := strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		`"`, "&quot;",
+		"'", "&#39;",
+	)
+	return r.Replace(s)
+}
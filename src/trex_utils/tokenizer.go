@@ -0,0 +1,278 @@
+package trex_utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TokenKind classifies a Token produced by Tokenize.
+type TokenKind int
+
+const (
+	// TokenWord is a plain argument: a command name, flag, or value.
+	TokenWord TokenKind = iota
+	// TokenPipe is an unquoted "|" separating pipeline stages.
+	TokenPipe
+	// TokenRedirectOut is an unquoted ">" (truncate-and-write stdout).
+	TokenRedirectOut
+	// TokenRedirectAppend is an unquoted ">>" (append stdout).
+	TokenRedirectAppend
+	// TokenRedirectErr is an unquoted "2>" (redirect stderr).
+	TokenRedirectErr
+)
+
+// Token is one lexeme of a tokenized command line. Operators (TokenPipe,
+// TokenRedirect*) carry their literal spelling in Value too, so callers that
+// only care about the flat text (ParseCommand) can use it unchanged.
+type Token struct {
+	Kind  TokenKind
+	Value string
+}
+
+// EnvResolver looks up a shell variable by name for $VAR/${VAR} expansion.
+// It mirrors the (string, bool) shape of os.LookupEnv rather than returning
+// "" for both "unset" and "set to empty", so a resolver can distinguish the
+// two if it wants to.
+type EnvResolver func(name string) (string, bool)
+
+// DefaultEnvResolver resolves variables from the process environment.
+func DefaultEnvResolver(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// Tokenize lexes line with DefaultEnvResolver. Most callers want this; use
+// TokenizeWithEnv directly to substitute a different variable source (for
+// example the shell's own in-memory variables instead of the OS environment).
+func Tokenize(line string) ([]Token, error) {
+	return TokenizeWithEnv(line, DefaultEnvResolver)
+}
+
+// TokenizeWithEnv is a proper POSIX-ish lexer for a t-rex command line. It
+// understands:
+//
+//   - single quotes: everything inside is literal, no escapes, no expansion
+//   - double quotes: \" \\ \$ are recognized escapes, $VAR/${VAR} still expand
+//   - unquoted \: escapes the next rune literally (no expansion of it)
+//   - unquoted $VAR / ${VAR}: expanded via resolve
+//   - unquoted |: a first-class TokenPipe, even if it sits hard against a word
+//   - unquoted >, >>, and a bare leading digit immediately followed by >
+//     (2>): first-class redirect tokens, not folded into the surrounding word
+//
+// This replaces the historical ParseCommand/NewPipeline behavior of
+// splitting on raw bytes, which broke on quoted "|" and ignored escapes
+// entirely.
+func TokenizeWithEnv(line string, resolve EnvResolver) ([]Token, error) {
+	var tokens []Token
+	var word strings.Builder
+	haveWord := false
+
+	flush := func() {
+		if haveWord {
+			tokens = append(tokens, Token{Kind: TokenWord, Value: word.String()})
+			word.Reset()
+			haveWord = false
+		}
+	}
+
+	expand := func(runes []rune, i int) (string, int, error) {
+		// runes[i] == '$'
+		if i+1 >= len(runes) {
+			return "$", i + 1, nil
+		}
+		if runes[i+1] == '{' {
+			end := strings.IndexRune(string(runes[i+2:]), '}')
+			if end < 0 {
+				return "", 0, fmt.Errorf("unterminated ${...} in command line")
+			}
+			name := string(runes[i+2 : i+2+end])
+			val, _ := resolve(name)
+			return val, i + 2 + end + 1, nil
+		}
+		j := i + 1
+		for j < len(runes) && (isAlnum(runes[j]) || runes[j] == '_') {
+			j++
+		}
+		if j == i+1 {
+			return "$", j, nil
+		}
+		name := string(runes[i+1 : j])
+		val, _ := resolve(name)
+		return val, j, nil
+	}
+
+	runes := []rune(line)
+	n := len(runes)
+	for i := 0; i < n; {
+		c := runes[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			flush()
+			i++
+
+		case c == '\'':
+			haveWord = true
+			i++
+			closed := false
+			for i < n {
+				if runes[i] == '\'' {
+					closed = true
+					i++
+					break
+				}
+				word.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated single-quoted string in command line")
+			}
+
+		case c == '"':
+			haveWord = true
+			i++
+			closed := false
+			for i < n {
+				switch runes[i] {
+				case '"':
+					closed = true
+					i++
+				case '\\':
+					if i+1 < n && (runes[i+1] == '"' || runes[i+1] == '\\' || runes[i+1] == '$') {
+						word.WriteRune(runes[i+1])
+						i += 2
+						continue
+					}
+					word.WriteRune(runes[i])
+					i++
+				case '$':
+					val, next, err := expand(runes, i)
+					if err != nil {
+						return nil, err
+					}
+					word.WriteString(val)
+					i = next
+					continue
+				default:
+					word.WriteRune(runes[i])
+					i++
+				}
+				if closed {
+					break
+				}
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double-quoted string in command line")
+			}
+
+		case c == '\\':
+			if i+1 >= n {
+				return nil, fmt.Errorf("trailing backslash in command line")
+			}
+			haveWord = true
+			word.WriteRune(runes[i+1])
+			i += 2
+
+		case c == '$':
+			haveWord = true
+			val, next, err := expand(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			word.WriteString(val)
+			i = next
+
+		case c == '|':
+			flush()
+			tokens = append(tokens, Token{Kind: TokenPipe, Value: "|"})
+			i++
+
+		case c == '>':
+			flush()
+			if i+1 < n && runes[i+1] == '>' {
+				tokens = append(tokens, Token{Kind: TokenRedirectAppend, Value: ">>"})
+				i += 2
+			} else {
+				tokens = append(tokens, Token{Kind: TokenRedirectOut, Value: ">"})
+				i++
+			}
+
+		case c == '2' && i+1 < n && runes[i+1] == '>' && !haveWord:
+			tokens = append(tokens, Token{Kind: TokenRedirectErr, Value: "2>"})
+			i += 2
+
+		default:
+			haveWord = true
+			word.WriteRune(c)
+			i++
+		}
+	}
+	flush()
+
+	return tokens, nil
+}
+
+func isAlnum(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// SplitUnquotedPipes splits line into pipeline-stage substrings at each
+// unquoted "|", tracking single/double-quote and backslash-escape state
+// (the same quoting rules Tokenize uses) without otherwise touching the
+// text — each returned stage is still raw, still-quoted source, just
+// trimmed of surrounding whitespace, ready for a caller to tokenize itself
+// (ParseCommand/Tokenize) to get its argv. This is what lets
+// `grep "a|b" | pp` split into exactly two stages instead of three.
+//
+// On malformed input (unterminated quote, trailing backslash), it falls
+// back to a naive split on every "|" so callers degrade the same way they
+// always did rather than erroring deep inside pipeline dispatch.
+func SplitUnquotedPipes(line string) []string {
+	runes := []rune(line)
+	n := len(runes)
+
+	var stages []string
+	start := 0
+	var quote rune // 0, '\'', or '"'
+
+	flush := func(end int) {
+		stages = append(stages, strings.TrimSpace(string(runes[start:end])))
+	}
+
+	for i := 0; i < n; i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' && i+1 < n {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '\\':
+			if i+1 >= n {
+				return naiveSplitPipes(line)
+			}
+			i++
+		case c == '|':
+			flush(i)
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return naiveSplitPipes(line)
+	}
+	flush(n)
+
+	return stages
+}
+
+func naiveSplitPipes(line string) []string {
+	parts := strings.Split(line, "|")
+	stages := make([]string, len(parts))
+	for i, p := range parts {
+		stages[i] = strings.TrimSpace(p)
+	}
+	return stages
+}
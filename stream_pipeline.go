@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"trex_utils"
+)
+
+// stripStreamFlag removes the first "--stream" flag from a command's
+// arguments, wherever it appears, reporting whether it was present.
+// `cmd args... --stream` opts
+// the invocation into runStreamingPipeline instead of the regular
+// fully-buffered executeModule path.
+func stripStreamFlag(args []string) ([]string, bool) {
+	for i, a := range args {
+		if a == "--stream" {
+			out := make([]string, 0, len(args)-1)
+			out = append(out, args[:i]...)
+			out = append(out, args[i+1:]...)
+			return out, true
+		}
+	}
+	return args, false
+}
+
+// streamSelectStage narrows every record to the requested fields as it
+// passes through, without ever holding more than one record at a time.
+func streamSelectStage(in <-chan map[string]interface{}, fields []string) <-chan map[string]interface{} {
+	out := make(chan map[string]interface{})
+	go func() {
+		defer close(out)
+		for rec := range in {
+			out <- trex_utils.SelectFields(rec, fields)
+		}
+	}()
+	return out
+}
+
+// streamMapStage evaluates expr against every record as it arrives. A
+// non-object result is wrapped under a "value" key so downstream stages
+// (and the final table/JSON rendering) still see a record shape.
+func streamMapStage(in <-chan map[string]interface{}, expr string) (<-chan map[string]interface{}, <-chan error) {
+	out := make(chan map[string]interface{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errs)
+		vm := newPipelineVM()
+		for rec := range in {
+			v, err := evalPipelineExpr(vm, expr, rec)
+			if err != nil {
+				errs <- err
+				return
+			}
+			mapped, ok := v.(map[string]interface{})
+			if !ok {
+				mapped = map[string]interface{}{"value": v}
+			}
+			out <- mapped
+		}
+	}()
+	return out, errs
+}
+
+// streamFilterStage keeps only the records for which expr is truthy,
+// evaluated one record at a time rather than against a materialized array.
+func streamFilterStage(in <-chan map[string]interface{}, expr string) (<-chan map[string]interface{}, <-chan error) {
+	out := make(chan map[string]interface{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errs)
+		vm := newPipelineVM()
+		for rec := range in {
+			v, err := evalPipelineExpr(vm, expr, rec)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if vm.ToValue(v).ToBoolean() {
+				out <- rec
+			}
+		}
+	}()
+	return out, errs
+}
+
+// runStreamingPipeline is the record-channel counterpart to executePipeline,
+// used when a module is invoked with a trailing "--stream" flag. cmd must
+// resolve to a python module: ExecuteStream (and therefore JSON Lines
+// stdout) is currently only implemented by PythonExecutor, the same
+// restriction InvokeWarm's warm-worker path places on python/node.
+//
+// Only select/filter/map can run mid-stream, since each processes one
+// record independently; they may be followed by a single terminal "tt" to
+// render rows as they arrive instead of after the module exits. Any other
+// stage (pp, template, columns, a further module name, or a stage
+// appearing after tt) isn't representable without materializing the
+// stream, so it's reported as an error rather than silently buffering.
+func (s *Shell) runStreamingPipeline(moduleName string, args []string, rawStages []string) error {
+	_, adapter, err := s.loader.FindModule(moduleName)
+	if err != nil {
+		s.printModuleNotFound([]string{moduleName})
+		return os.ErrNotExist
+	}
+	if adapter.Name() != "python" {
+		return fmt.Errorf("--stream: only python modules support streaming output, %s is a %s module", moduleName, adapter.Name())
+	}
+
+	records, srcErrs := s.executor.ExecuteStream(moduleName, args)
+
+	errs := make(chan error, 4)
+	forward := func(c <-chan error) {
+		go func() {
+			if e, ok := <-c; ok && e != nil {
+				select {
+				case errs <- e:
+				default:
+				}
+			}
+		}()
+	}
+	forward(srcErrs)
+
+	cur := records
+	tableMode := false
+	for i, raw := range rawStages {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		cmdParts := trex_utils.ParseCommand(raw)
+		if len(cmdParts) == 0 {
+			continue
+		}
+		op, opArgs := cmdParts[0], cmdParts[1:]
+
+		if tableMode {
+			return fmt.Errorf("--stream: \"tt\" must be the last pipeline stage, found %q after it", op)
+		}
+
+		switch op {
+		case "select":
+			cur = streamSelectStage(cur, opArgs)
+		case "map":
+			if len(opArgs) == 0 {
+				return fmt.Errorf("map: expected a JS expression argument")
+			}
+			var mErrs <-chan error
+			cur, mErrs = streamMapStage(cur, strings.Join(opArgs, " "))
+			forward(mErrs)
+		case "filter":
+			if len(opArgs) == 0 {
+				return fmt.Errorf("filter: expected a JS expression argument")
+			}
+			var fErrs <-chan error
+			cur, fErrs = streamFilterStage(cur, strings.Join(opArgs, " "))
+			forward(fErrs)
+		case "tt":
+			tableMode = true
+		default:
+			return fmt.Errorf("--stream: %q cannot run mid-stream (stage %d of pipeline)", op, i+1)
+		}
+	}
+
+	if tableMode {
+		err := streamTablePrint(s.out(), cur)
+		if first := firstErr(errs); first != nil {
+			return first
+		}
+		return err
+	}
+
+	// No terminal "tt": materialize into the regular array-shaped result
+	// and reuse printResult so JSON/pp/output_template formatting still
+	// applies uniformly.
+	var rows []interface{}
+	for rec := range cur {
+		rows = append(rows, rec)
+	}
+	if first := firstErr(errs); first != nil {
+		return first
+	}
+	s.printResult(map[string]interface{}{"output": rows, "status": "success"})
+	return nil
+}
+
+// firstErr drains a buffered error channel without blocking, returning the
+// first error if one was reported.
+func firstErr(errs chan error) error {
+	select {
+	case e := <-errs:
+		return e
+	default:
+		return nil
+	}
+}
+
+// streamTablePrint renders records as they arrive, one JSON line per row.
+// It deliberately doesn't attempt tabwriter-style column alignment since
+// that requires seeing every row's column set up front, which defeats the
+// point of streaming; aligned columns are better served by the
+// non-streaming "tt".
+func streamTablePrint(out io.Writer, records <-chan map[string]interface{}) error {
+	for rec := range records {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("--stream: failed to encode row: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+	}
+	return nil
+}
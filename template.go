@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"trex_errors"
+)
+
+// expandVars expands both the legacy `$var` / `${var}` forms and the
+// richer `%{namespace:arg}` template grammar shared by scripts, the REPL,
+// and forloop/foreach bodies:
+//
+//	%{env:PATH}            - environment variable
+//	%{bin:python}          - absolute path via exec.LookPath
+//	%{path:some/file}      - absolute path, relative to the running script
+//	%{module:hash256}      - absolute path to a module, via the loader
+//	%{@}                   - the most recent pipeline output
+//	%{var:NAME-default}    - shell variable NAME, or "default" if unset
+//
+// `\%{` escapes a literal `%{` without expansion. Namespace args may
+// themselves contain `%{...}` templates, which are expanded before the
+// outer namespace is resolved.
+func (s *Shell) expandVars(input string) (string, error) {
+	var out strings.Builder
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		// \%{  ->  literal %{
+		if r == '\\' && i+2 < len(runes) && runes[i+1] == '%' && runes[i+2] == '{' {
+			out.WriteString("%{")
+			i += 2
+			continue
+		}
+
+		if r == '%' && i+1 < len(runes) && runes[i+1] == '{' {
+			inner, endIdx, err := extractBraced(runes, i+1)
+			if err != nil {
+				return "", err
+			}
+			expandedInner, err := s.expandVars(inner)
+			if err != nil {
+				return "", err
+			}
+			value, err := s.resolveTemplate(expandedInner, input, i)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i = endIdx
+			continue
+		}
+
+		if r == '$' {
+			name, consumed := scanDollarVar(runes[i:])
+			if name != "" {
+				out.WriteString(s.vars[name])
+				i += consumed - 1
+				continue
+			}
+		}
+
+		out.WriteRune(r)
+	}
+
+	return out.String(), nil
+}
+
+// extractBraced finds the `{...}` span starting at openIdx (which must
+// point at the '{'), honoring nested braces, and returns its inner text
+// plus the index of the matching '}'.
+func extractBraced(runes []rune, openIdx int) (inner string, closeIdx int, err error) {
+	depth := 0
+	for i := openIdx; i < len(runes); i++ {
+		switch runes[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return string(runes[openIdx+1 : i]), i, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated %%{...} starting at column %d", openIdx)
+}
+
+// scanDollarVar matches the legacy $name / ${name} forms at the start of
+// runes, returning the variable name and how many runes it consumed (0 if
+// runes doesn't start with a valid reference).
+func scanDollarVar(runes []rune) (name string, consumed int) {
+	if len(runes) < 2 {
+		return "", 0
+	}
+	if runes[1] == '{' {
+		for i := 2; i < len(runes); i++ {
+			if runes[i] == '}' {
+				return string(runes[2:i]), i + 1
+			}
+		}
+		return "", 0
+	}
+	i := 1
+	for i < len(runes) && isVarNameRune(runes[i], i == 1) {
+		i++
+	}
+	if i == 1 {
+		return "", 0
+	}
+	return string(runes[1:i]), i
+}
+
+func isVarNameRune(r rune, first bool) bool {
+	if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+		return true
+	}
+	if !first && r >= '0' && r <= '9' {
+		return true
+	}
+	return false
+}
+
+// resolveTemplate resolves one already-inner-expanded `%{...}` body
+// (namespace:arg, or the bare "@") against shell state. origLine/col are
+// only used to build a diagnostic if the namespace is unrecognized.
+func (s *Shell) resolveTemplate(body, origLine string, col int) (string, error) {
+	if body == "@" {
+		return s.lastOutput, nil
+	}
+
+	parts := strings.SplitN(body, ":", 2)
+	namespace := parts[0]
+	arg := ""
+	if len(parts) == 2 {
+		arg = parts[1]
+	}
+
+	switch namespace {
+	case "env":
+		return os.Getenv(arg), nil
+
+	case "bin":
+		path, err := exec.LookPath(arg)
+		if err != nil {
+			return "", fmt.Errorf("%%{bin:%s}: %w", arg, err)
+		}
+		return path, nil
+
+	case "path":
+		dir := s.currentScriptDir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		if filepath.IsAbs(arg) {
+			return arg, nil
+		}
+		return filepath.Join(dir, arg), nil
+
+	case "module":
+		path, _, err := s.loader.FindModule(arg)
+		if err != nil {
+			return "", fmt.Errorf("%%{module:%s}: module not found", arg)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return path, nil
+		}
+		return abs, nil
+
+	case "var":
+		name := arg
+		def := ""
+		if idx := strings.Index(arg, "-"); idx >= 0 {
+			name = arg[:idx]
+			def = arg[idx+1:]
+		}
+		if v, ok := s.vars[name]; ok {
+			return v, nil
+		}
+		return def, nil
+
+	default:
+		return "", s.unknownNamespaceError(namespace, origLine, col)
+	}
+}
+
+// unknownNamespaceError builds a rust-style diagnostic pointing at the
+// column where the unrecognized namespace starts in the original line.
+// col is a rune index into origLine (expandVars walks a []rune), but
+// report.AddLabel expects a byte offset, so it's converted here before any
+// multibyte rune preceding the "%{" can throw the caret off.
+func (s *Shell) unknownNamespaceError(namespace, origLine string, col int) error {
+	runes := []rune(origLine)
+	if col > len(runes) {
+		col = len(runes)
+	}
+	byteCol := len(string(runes[:col]))
+	nsStart := byteCol + 2 // skip past "%{"
+	report := trex_errors.NewReport(trex_errors.SourceMap{"<template>": origLine})
+	report.AddLabel("<template>", nsStart, nsStart+len(namespace), "unknown template namespace", "")
+	report.WithHelp("expected one of: env, bin, path, module, var, or @")
+	return fmt.Errorf("%s", report.Format())
+}